@@ -25,6 +25,7 @@ import (
 
 	"ozz-ms/pkg/media_index"
 
+	"github.com/gosuri/uitable"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -32,6 +33,12 @@ import (
 
 const (
 	OVERWRITE_FLAG_NAME = "overwrite"
+	ANALYZER_FLAG_NAME  = "analyzer"
+	WORKERS_FLAG_NAME   = "workers"
+	FROM_FLAG_NAME      = "from"
+	SIZE_FLAG_NAME      = "size"
+	SORT_FLAG_NAME      = "sort"
+	DRY_RUN_FLAG_NAME   = "dry-run"
 )
 
 // indexCmd represents the media_index command
@@ -55,6 +62,15 @@ var createCmd = &cobra.Command{
 			return errors.New("no media_index name specified")
 		}
 
+		filter := walkFilter()
+		dryRun, err := cmd.Flags().GetBool(DRY_RUN_FLAG_NAME)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return listAudioFiles(cmd, args, filter)
+		}
+
 		absIndexPath, err := filepath.Abs(indexName)
 		if err != nil {
 			return err
@@ -91,13 +107,23 @@ var createCmd = &cobra.Command{
 				//progressbar.OptionClearOnFinish(),
 			)
 		}
+		analyzers, err := fieldAnalyzers()
+		if err != nil {
+			return err
+		}
+
 		index := media_index.NewIndex(absIndexPath)
+		index.Analyzers = analyzers
 		err = index.Create()
 		if err != nil {
 			return err
 		}
 
-		audioFiles, err := media_index.NewAudioWalker(args)
+		audioFiles, err := media_index.NewAudioWalker(args, media_index.WalkerOptions{
+			Workers:  viper.GetInt(WORKERS_FLAG_NAME),
+			Metadata: viper.GetString(METADATA_FLAG_NAME),
+			Filter:   filter,
+		})
 		if err != nil {
 			return err
 		}
@@ -121,7 +147,7 @@ var createCmd = &cobra.Command{
 					break
 				}
 				printVerbose(cmd, "Added:", audioFile.Path)
-				if err = index.AddItem(audioFile); err != nil {
+				if _, err = index.AddFile(&audioFile); err != nil {
 					doit = false
 					break
 				}
@@ -129,9 +155,6 @@ var createCmd = &cobra.Command{
 					_ = progressBar.Add(1)
 				}
 				numberOfDocs++
-			case <-audioFiles.Finished:
-				doit = false
-				break
 			case <-audioFiles.Progress:
 				break
 			}
@@ -154,6 +177,190 @@ var createCmd = &cobra.Command{
 	},
 }
 
+var updateCmd = &cobra.Command{
+	Use:   "update [folder-to-media...]",
+	Short: "Update search index",
+	Long: `Update existing media index, reading metadata only for new or modified files and removing deleted ones.
+Without folders, all folders already in the index are updated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		indexName := viper.GetString(INDEX_NAME_FLAG_NAME)
+		verbose := viper.GetBool(VERBOSE_FLAG_NAME)
+
+		absIndexPath, err := filepath.Abs(indexName)
+		if err != nil {
+			return err
+		}
+		cmd.Println("Update index:", absIndexPath)
+
+		index := media_index.NewIndex(absIndexPath)
+		if err := index.Open(); err != nil {
+			return err
+		}
+		defer index.Close()
+
+		roots := args
+		if len(roots) == 0 {
+			if roots, err = index.Roots(); err != nil {
+				return err
+			}
+			if len(roots) == 0 {
+				return errors.New("media index is empty, specify folders to index")
+			}
+		}
+
+		var progressBar *progressbar.ProgressBar
+		if !verbose {
+			progressBar = progressbar.NewOptions(-1,
+				progressbar.OptionEnableColorCodes(true),
+				progressbar.OptionSetDescription("[cyan]Updating...[reset]"),
+				progressbar.OptionShowCount(),
+				progressbar.OptionShowIts(),
+				progressbar.OptionSetItsString("items"),
+				progressbar.OptionOnCompletion(func() {
+					fmt.Printf("\n")
+				}),
+				progressbar.OptionSpinnerType(14),
+				progressbar.OptionFullWidth(),
+				progressbar.OptionThrottle(65*time.Millisecond),
+			)
+		}
+
+		options := media_index.WalkerOptions{
+			Workers:  viper.GetInt(WORKERS_FLAG_NAME),
+			Metadata: viper.GetString(METADATA_FLAG_NAME),
+			Filter:   walkFilter(),
+		}
+		report, err := index.Update(roots, options, func(item media_index.AudioFile) {
+			printVerbose(cmd, "Indexed:", item.Path)
+			if progressBar != nil {
+				_ = progressBar.Add(1)
+			}
+		})
+		if progressBar != nil {
+			_ = progressBar.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		cmd.Println("MediaIndex updated, added:", report.Added, "updated:", report.Updated,
+			"moved:", report.Moved, "removed:", report.Removed, "unchanged:", report.Unchanged)
+		return nil
+	},
+}
+
+var rebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild search index",
+	Long: `Rebuild existing media index with current field analyzers, without walking media folders again.
+Use it after changing analyzers, or to upgrade index created by older version.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		indexName := viper.GetString(INDEX_NAME_FLAG_NAME)
+
+		absIndexPath, err := filepath.Abs(indexName)
+		if err != nil {
+			return err
+		}
+		cmd.Println("Rebuild index:", absIndexPath)
+
+		analyzers, err := fieldAnalyzers()
+		if err != nil {
+			return err
+		}
+
+		index := media_index.NewIndex(absIndexPath)
+		index.Analyzers = analyzers
+		numberOfDocs, err := index.Rebuild()
+		if err != nil {
+			return err
+		}
+		cmd.Println("MediaIndex rebuilt, documents:", numberOfDocs)
+		return nil
+	},
+}
+
+var duplicatesCmd = &cobra.Command{
+	Use:   "duplicates",
+	Short: "List duplicate media files",
+	Long:  `List files with same audio content found at different paths, regardless of their tags.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		indexName := viper.GetString(INDEX_NAME_FLAG_NAME)
+
+		index := media_index.NewIndex(indexName)
+		if err := index.Open(); err != nil {
+			return err
+		}
+		defer index.Close()
+
+		duplicates, err := index.Duplicates()
+		if err != nil {
+			return err
+		}
+		table := uitable.New()
+		table.MaxColWidth = 120
+		table.Wrap = true
+		files := 0
+		for _, group := range duplicates {
+			for _, item := range group {
+				table.AddRow(item.ID, item.Path)
+				files++
+			}
+			table.AddRow("")
+		}
+		cmd.Println(table)
+		cmd.Println("Total:", files, "files with", len(duplicates), "different contents.")
+		return nil
+	},
+}
+
+// fieldAnalyzers parses field=analyzer pairs from flags or config
+func fieldAnalyzers() (map[string]string, error) {
+	analyzers := map[string]string{}
+	for _, pair := range viper.GetStringSlice(ANALYZER_FLAG_NAME) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid analyzer %q, expected field=analyzer", pair)
+		}
+		analyzers[kv[0]] = kv[1]
+	}
+	return media_index.FieldAnalyzers(analyzers)
+}
+
+// walkFilter reads media files filter from flags or config
+func walkFilter() media_index.WalkFilter {
+	return media_index.WalkFilter{
+		Include:        viper.GetStringSlice(INCLUDE_FLAG_NAME),
+		Exclude:        viper.GetStringSlice(EXCLUDE_FLAG_NAME),
+		MaxDepth:       viper.GetInt(MAX_DEPTH_FLAG_NAME),
+		FollowSymlinks: viper.GetBool(FOLLOW_SYMLINKS_FLAG_NAME),
+		Hidden:         viper.GetBool(HIDDEN_FLAG_NAME),
+	}
+}
+
+// listAudioFiles prints audio files that would be indexed under roots
+func listAudioFiles(cmd *cobra.Command, roots []string, filter media_index.WalkFilter) error {
+	audioFiles, err := media_index.NewAudioWalker(roots, media_index.WalkerOptions{
+		Workers:  viper.GetInt(WORKERS_FLAG_NAME),
+		Filter:   filter,
+		FindOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	numberOfFiles := 0
+	for audioFile := range audioFiles.File {
+		cmd.Println(audioFile.Path)
+		numberOfFiles++
+	}
+	cmd.Println("Total:", numberOfFiles, "files would be indexed.")
+	return nil
+}
+
 func printVerbose(cmd *cobra.Command, message ...interface{}) {
 	verbose, err := cmd.Flags().GetBool(VERBOSE_FLAG_NAME)
 	if err == nil {
@@ -167,7 +374,10 @@ var queryCmd = &cobra.Command{
 	Use:   "query",
 	Args:  cobra.MinimumNArgs(1),
 	Short: "Query media search index",
-	Long:  `Query for media index`,
+	Long: `Query for media index
+
+Query uses bleve query string syntax, duration can be limited with ranges:
+  duration:<30s  duration:>=3:00  duration:1m..2m30s  -duration:..10s`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		indexName := viper.GetString(INDEX_NAME_FLAG_NAME)
@@ -175,16 +385,27 @@ var queryCmd = &cobra.Command{
 
 		index := media_index.NewIndex(indexName)
 		index.Verbose = verbose
-		if err := index.Open(); err != nil {
+		err := index.Open()
+		if err != nil {
 			return err
 		}
 		queryString := strings.Join(args, " ")
-		res, err := index.Query(queryString)
+		options := media_index.SearchOptions{}
+		if options.From, err = cmd.Flags().GetInt(FROM_FLAG_NAME); err != nil {
+			return err
+		}
+		if options.Size, err = cmd.Flags().GetInt(SIZE_FLAG_NAME); err != nil {
+			return err
+		}
+		if options.Sort, err = cmd.Flags().GetStringSlice(SORT_FLAG_NAME); err != nil {
+			return err
+		}
+		res, total, err := index.Query(queryString, options)
 		if err != nil {
 			return err
 		}
 		res.WriteOut()
-		cmd.Println("Total:", len(res), " found.")
+		cmd.Println("Total:", total, " found.")
 		if err = index.Close(); err != nil {
 			return err
 		}
@@ -195,8 +416,19 @@ var queryCmd = &cobra.Command{
 func init() {
 	indexCmd.AddCommand(createCmd)
 	indexCmd.AddCommand(queryCmd)
+	indexCmd.AddCommand(updateCmd)
+	indexCmd.AddCommand(rebuildCmd)
+	indexCmd.AddCommand(duplicatesCmd)
+
+	indexCmd.PersistentFlags().StringSlice(ANALYZER_FLAG_NAME, []string{},
+		fmt.Sprintf("field analyzer as field=analyzer, e.g. Name=%s or Folder=keyword", media_index.SerbianAnalyzerName))
 
 	createCmd.Flags().Bool(OVERWRITE_FLAG_NAME, false, "overwrite media index if exists")
+	queryCmd.Flags().Int(FROM_FLAG_NAME, 0, "number of matches to skip")
+	queryCmd.Flags().Int(SIZE_FLAG_NAME, media_index.DefaultSearchSize, "number of matches to show")
+	queryCmd.Flags().StringSlice(SORT_FLAG_NAME, []string{}, "fields to sort by, prefixed with - for descending order, e.g. -Year,Name")
+	indexCmd.PersistentFlags().Int(WORKERS_FLAG_NAME, media_index.DefaultWorkers, "number of files read in parallel")
+	createCmd.Flags().Bool(DRY_RUN_FLAG_NAME, false, "list files that would be indexed, without creating index")
 	rootCmd.AddCommand(indexCmd)
 
 	viper.BindPFlags(indexCmd.PersistentFlags())
@@ -31,6 +31,14 @@ const (
 	VERBOSE_FLAG_NAME    = "verbose"
 	INDEX_NAME_FLAG_NAME = "index-name"
 	PORT_FLAG_NAME       = "port"
+	METADATA_FLAG_NAME   = "metadata"
+
+	// media files filter, shared by index commands and watching service
+	INCLUDE_FLAG_NAME         = "include"
+	EXCLUDE_FLAG_NAME         = "exclude"
+	MAX_DEPTH_FLAG_NAME       = "max-depth"
+	FOLLOW_SYMLINKS_FLAG_NAME = "follow-symlinks"
+	HIDDEN_FLAG_NAME          = "hidden"
 )
 
 var (
@@ -55,6 +63,13 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ozz-ms.yaml)")
 	rootCmd.PersistentFlags().BoolP(VERBOSE_FLAG_NAME, "v", false, "display verbose output")
 	rootCmd.PersistentFlags().String(INDEX_NAME_FLAG_NAME, media_index.DefaultIndexName, "index name")
+	rootCmd.PersistentFlags().String(METADATA_FLAG_NAME, media_index.DefaultMetadata,
+		fmt.Sprintf("metadata reader: %s, %s or %s", media_index.MetadataAuto, media_index.MetadataExiftool, media_index.MetadataBuiltin))
+	rootCmd.PersistentFlags().StringSlice(INCLUDE_FLAG_NAME, []string{}, "glob patterns of files to index, e.g. *.mp3,*.flac")
+	rootCmd.PersistentFlags().StringSlice(EXCLUDE_FLAG_NAME, []string{}, "glob patterns of files and folders not to index, e.g. *.bak,backup/*")
+	rootCmd.PersistentFlags().Int(MAX_DEPTH_FLAG_NAME, 0, "folder levels to walk, 1 for files directly in media folder, 0 for all")
+	rootCmd.PersistentFlags().Bool(FOLLOW_SYMLINKS_FLAG_NAME, false, "index linked files and folders")
+	rootCmd.PersistentFlags().Bool(HIDDEN_FLAG_NAME, false, "index hidden files and folders")
 	viper.BindPFlags(rootCmd.PersistentFlags())
 }
 
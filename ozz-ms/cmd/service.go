@@ -20,8 +20,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"ozz-ms/pkg/media_index"
 	"ozz-ms/pkg/server"
 
 	"github.com/kardianos/service"
@@ -30,6 +32,9 @@ import (
 )
 
 const (
+	WATCH_FLAG_NAME       = "watch"
+	WATCH_DELAY_FLAG_NAME = "watch-delay"
+
 	serviceName        = "OZZZZZZMS"
 	serviceDisplayName = "OZZZZZZ Media Server"
 	serviceDescription = "OZZ replacement media media_index / file server"
@@ -97,10 +102,18 @@ var serviceCmd = &cobra.Command{
 
 		port := viper.GetInt(PORT_FLAG_NAME)
 		indexName := viper.GetString(INDEX_NAME_FLAG_NAME)
+		watch := viper.GetBool(WATCH_FLAG_NAME)
+		watchDelay := viper.GetDuration(WATCH_DELAY_FLAG_NAME)
+		metadata := viper.GetString(METADATA_FLAG_NAME)
 
 		cfg := server.OzzServerConfig{
-			Port:      port,
-			IndexName: indexName,
+			Port:       port,
+			IndexName:  indexName,
+			Watch:      watch,
+			WatchDelay: watchDelay,
+			Metadata:   metadata,
+			// same filter as index commands
+			Filter: walkFilter(),
 		}
 		srv := server.NewOzzServer(cfg)
 
@@ -117,15 +130,32 @@ var runCmd = &cobra.Command{
 	Long:  `Run audio media server`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var err error
+		arguments := []string{
+			INDEX_NAME_FLAG_NAME, runner.server.Config.IndexName,
+			"service", "run",
+			PORT_FLAG_NAME, fmt.Sprintf("%d", runner.server.Config.Port),
+		}
+		if runner.server.Config.Watch {
+			filter := runner.server.Config.Filter
+			arguments = append(arguments,
+				"--"+WATCH_FLAG_NAME,
+				"--"+WATCH_DELAY_FLAG_NAME, runner.server.Config.WatchDelay.String(),
+				"--"+METADATA_FLAG_NAME, runner.server.Config.Metadata,
+				"--"+MAX_DEPTH_FLAG_NAME, fmt.Sprintf("%d", filter.MaxDepth),
+				fmt.Sprintf("--%s=%t", FOLLOW_SYMLINKS_FLAG_NAME, filter.FollowSymlinks),
+				fmt.Sprintf("--%s=%t", HIDDEN_FLAG_NAME, filter.Hidden))
+			if len(filter.Include) > 0 {
+				arguments = append(arguments, "--"+INCLUDE_FLAG_NAME, strings.Join(filter.Include, ","))
+			}
+			if len(filter.Exclude) > 0 {
+				arguments = append(arguments, "--"+EXCLUDE_FLAG_NAME, strings.Join(filter.Exclude, ","))
+			}
+		}
 		createdService, err = service.New(runner, &service.Config{
 			Name:        serviceName,
 			DisplayName: serviceDisplayName,
 			Description: serviceDescription,
-			Arguments: []string{
-				INDEX_NAME_FLAG_NAME, runner.server.Config.IndexName,
-				"service", "run",
-				PORT_FLAG_NAME, fmt.Sprintf("%d", runner.server.Config.Port),
-			},
+			Arguments:   arguments,
 		})
 		if err != nil {
 			return err
@@ -144,6 +174,8 @@ func init() {
 	rootCmd.AddCommand(serviceCmd)
 
 	serviceCmd.PersistentFlags().IntP(PORT_FLAG_NAME, "p", 26000, "port to serve on")
+	serviceCmd.PersistentFlags().Bool(WATCH_FLAG_NAME, false, "watch indexed media folders and keep index in sync")
+	serviceCmd.PersistentFlags().Duration(WATCH_DELAY_FLAG_NAME, media_index.DefaultWatchDelay, "wait for changes to settle before indexing them")
 	viper.BindPFlags(serviceCmd.PersistentFlags())
 
 }
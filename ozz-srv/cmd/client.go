@@ -0,0 +1,134 @@
+/*
+Copyright © 2022 kockicica@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"ozz-ms/pkg/data/model"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Client management",
+	Long:  `Use subcommands to list clients and merge duplicated ones`,
+}
+
+var clientListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List clients",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		clients := []model.Client{}
+		if err := repo.Clients("", &clients); err != nil {
+			return err
+		}
+
+		table := uitable.New()
+		table.AddRow("ID", "NAME", "CONTACT", "TAX ID")
+		for _, client := range clients {
+			table.AddRow(client.ID, client.Name, client.ContactPerson, client.TaxID)
+		}
+		fmt.Println(table)
+
+		return nil
+	},
+}
+
+var clientDuplicatesCmd = &cobra.Command{
+	Use:   "duplicates",
+	Short: "List clients with similar names",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		groups, err := repo.ClientDuplicates()
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			cmd.Println("No similar client names found")
+			return nil
+		}
+
+		table := uitable.New()
+		table.AddRow("KEY", "ID", "NAME")
+		for _, group := range groups {
+			for _, client := range group.Clients {
+				table.AddRow(group.Key, client.ID, client.Name)
+			}
+		}
+		fmt.Println(table)
+
+		return nil
+	},
+}
+
+var clientMergeCmd = &cobra.Command{
+	Use:   "merge <target id> <source id>...",
+	Short: "Move recordings of source clients to target client and remove sources",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		target, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid client id %s: %w", args[0], err)
+		}
+		sources := []uint{}
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid client id %s: %w", arg, err)
+			}
+			sources = append(sources, uint(id))
+		}
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		if err := repo.MergeClients(target, sources); err != nil {
+			return err
+		}
+		cmd.Printf("Merged %d client(s) into client %d\n", len(sources), target)
+
+		return nil
+	},
+}
+
+func init() {
+	clientCmd.AddCommand(clientListCmd)
+	clientCmd.AddCommand(clientDuplicatesCmd)
+	clientCmd.AddCommand(clientMergeCmd)
+
+	rootCmd.AddCommand(clientCmd)
+}
@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"ozz-ms/pkg/data/repository"
 	"ozz-ms/pkg/data/server"
 
 	"github.com/kardianos/service"
@@ -48,6 +50,11 @@ var installCmd = &cobra.Command{
 			return err
 		}
 
+		// index would otherwise end up in service working directory
+		if cfg.IndexPath, err = filepath.Abs(cfg.IndexPath); err != nil {
+			return err
+		}
+
 		if cfg.Dsn == "data.db" {
 			// without proper database url we need abs path
 			cfg.Dsn = filepath.Join(wd, "data.db")
@@ -73,6 +80,39 @@ var installCmd = &cobra.Command{
 			"--database", cfg.Dsn,
 			"--root", cfg.RootPath,
 			"--port", fmt.Sprintf("%d", cfg.Port),
+			"--token-ttl", cfg.TokenTTL.String(),
+			"--max-upload-size", cfg.MaxUploadSize,
+			"--allowed-audio-types", strings.Join(cfg.AllowedAudioTypes, ","),
+			"--index", cfg.IndexPath,
+			"--admin-user", cfg.AdminUsername,
+		}
+		if cfg.TokenSecret != "" {
+			// keep secret out of service definition and process list
+			secretConfig, err := writeSecretConfig(wd, cfg.TokenSecret)
+			if err != nil {
+				return err
+			}
+			serviceCfg.Arguments = append(serviceCfg.Arguments, "--config", secretConfig)
+		}
+		if cfg.AdminPassword != "" {
+			// seeded once here, password is not kept in service arguments
+			if err := checkPassword(cfg.AdminPassword); err != nil {
+				return err
+			}
+			repo, err := repository.NewRepository(repository.RepositoryConfig{
+				Dsn:     cfg.Dsn,
+				Verbose: cfg.Verbose,
+			})
+			if err != nil {
+				return err
+			}
+			created, err := repo.InitAdmin(cfg.AdminUsername, cfg.AdminPassword)
+			if err != nil {
+				return err
+			}
+			if created {
+				cmd.Printf("Admin user %s created\n", cfg.AdminUsername)
+			}
 		}
 
 		createdService, err = service.New(runner, serviceCfg)
@@ -89,6 +129,21 @@ var installCmd = &cobra.Command{
 	},
 }
 
+// secretConfigName is config file service reads its token secret from
+const secretConfigName = "ozz-srv.secret.yaml"
+
+// writeSecretConfig stores token secret in config file in dir, readable by its
+// owner only, and returns path of the file
+func writeSecretConfig(dir, tokenSecret string) (string, error) {
+	path := filepath.Join(dir, secretConfigName)
+	data := fmt.Sprintf("%s: %q\n", TOKEN_SECRET_FLAG, tokenSecret)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		return "", err
+	}
+	// existing file keeps its permissions on write
+	return path, os.Chmod(path, 0600)
+}
+
 var uninstallCmd = &cobra.Command{
 	Use:     "uninstall",
 	Aliases: []string{"u"},
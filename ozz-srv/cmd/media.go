@@ -0,0 +1,115 @@
+/*
+Copyright © 2022 kockicica@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"ozz-ms/pkg/data/reconcile"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	IMPORT_ORPHANS_FLAG   = "import-orphans"
+	QUARANTINE_FLAG       = "quarantine"
+	SKIP_HASH_FLAG        = "skip-hash"
+	UPDATE_CHECKSUMS_FLAG = "update-checksums"
+)
+
+var mediaCmd = &cobra.Command{
+	Use:   "media",
+	Short: "Media storage maintenance",
+	Long:  `Use subcommands to check media root against recordings stored in database`,
+}
+
+var mediaCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Reconcile recordings with files under media root",
+	Long: `Reports recordings whose files are missing or changed since upload, and files
+under media root not referenced by any recording. Orphaned audio files can be
+imported as inactive recordings, or moved to quarantine folder.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		importOrphans, _ := cmd.Flags().GetBool(IMPORT_ORPHANS_FLAG)
+		quarantine, _ := cmd.Flags().GetString(QUARANTINE_FLAG)
+		skipHash, _ := cmd.Flags().GetBool(SKIP_HASH_FLAG)
+		updateChecksums, _ := cmd.Flags().GetBool(UPDATE_CHECKSUMS_FLAG)
+
+		if importOrphans && quarantine != "" {
+			return errors.New("orphans can either be imported or quarantined, not both")
+		}
+
+		root := viper.GetString(ROOT_PATH_FLAG)
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		report, err := reconcile.Check(repo, reconcile.Options{
+			Root:          root,
+			SkipHash:      skipHash,
+			UpdateMissing: updateChecksums,
+			Exclude:       quarantine,
+		})
+		if err != nil {
+			return err
+		}
+		report.Print()
+
+		switch {
+		case importOrphans && len(report.Orphans) > 0:
+			imported, skipped := reconcile.ImportOrphans(repo, root, report.Orphans)
+			for _, rec := range imported {
+				cmd.Printf("Imported %s as inactive recording %s\n", rec.Path, rec.Name)
+			}
+			paths := make([]string, 0, len(skipped))
+			for path := range skipped {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				cmd.Printf("Skipped %s: %s\n", path, skipped[path])
+			}
+		case quarantine != "" && len(report.Orphans) > 0:
+			moved, err := reconcile.QuarantineOrphans(root, quarantine, report.Orphans)
+			for _, path := range moved {
+				cmd.Printf("Moved %s to quarantine\n", path)
+			}
+			if err != nil {
+				return fmt.Errorf("unable to quarantine orphans: %w", err)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	mediaCmd.AddCommand(mediaCheckCmd)
+
+	mediaCheckCmd.Flags().Bool(IMPORT_ORPHANS_FLAG, false, "import orphaned audio files as inactive recordings")
+	mediaCheckCmd.Flags().String(QUARANTINE_FLAG, "", "move orphaned files to this folder")
+	mediaCheckCmd.Flags().Bool(SKIP_HASH_FLAG, false, "compare file sizes only, without hashing content")
+	mediaCheckCmd.Flags().Bool(UPDATE_CHECKSUMS_FLAG, false, "record checksum and size of recordings which have none")
+
+	rootCmd.AddCommand(mediaCmd)
+}
@@ -0,0 +1,294 @@
+/*
+Copyright © 2022 kockicica@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const (
+	PASSWORD_FLAG = "password"
+	LEVEL_FLAG    = "level"
+
+	// minPasswordLength matches minLen of password in user DTOs
+	minPasswordLength = 6
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "User management",
+	Long:  `Use subcommands to list, add, modify or deactivate data server users`,
+}
+
+var userListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List users",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		users := model.Users{}
+		if err := repo.Users(&users); err != nil {
+			return err
+		}
+		users.Print()
+
+		return nil
+	},
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Add new user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		levelName, err := cmd.Flags().GetString(LEVEL_FLAG)
+		if err != nil {
+			return err
+		}
+		level, err := model.ParseUserLevel(levelName)
+		if err != nil {
+			return err
+		}
+
+		password, err := passwordFromFlagOrPrompt(cmd)
+		if err != nil {
+			return err
+		}
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		user, err := repo.NewUser(args[0], password, level)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("User %s (%s) created\n", user.Username, user.Level)
+
+		return nil
+	},
+}
+
+var userLevelCmd = &cobra.Command{
+	Use:   "level <username> <admin|regular>",
+	Short: "Change user level",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		level, err := model.ParseUserLevel(args[1])
+		if err != nil {
+			return err
+		}
+
+		repo, user, err := openRepositoryForUser(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := repo.SetUserLevel(int(user.ID), level); err != nil {
+			return err
+		}
+		cmd.Printf("User %s is now %s\n", user.Username, level)
+
+		return nil
+	},
+}
+
+var userPasswordCmd = &cobra.Command{
+	Use:     "passwd <username>",
+	Aliases: []string{"password"},
+	Short:   "Set user password",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		password, err := passwordFromFlagOrPrompt(cmd)
+		if err != nil {
+			return err
+		}
+
+		repo, user, err := openRepositoryForUser(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := repo.SetUserPassword(int(user.ID), password); err != nil {
+			return err
+		}
+		cmd.Printf("Password for %s changed\n", user.Username)
+
+		return nil
+	},
+}
+
+var userDeactivateCmd = &cobra.Command{
+	Use:   "deactivate <username>",
+	Short: "Deactivate user and revoke its sessions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, user, err := openRepositoryForUser(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := repo.SetUserActive(int(user.ID), false); err != nil {
+			return err
+		}
+		cmd.Printf("User %s deactivated\n", user.Username)
+
+		return nil
+	},
+}
+
+var userActivateCmd = &cobra.Command{
+	Use:   "activate <username>",
+	Short: "Activate previously deactivated user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, user, err := openRepositoryForUser(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := repo.SetUserActive(int(user.ID), true); err != nil {
+			return err
+		}
+		cmd.Printf("User %s activated\n", user.Username)
+
+		return nil
+	},
+}
+
+var userRemoveDefaultsCmd = &cobra.Command{
+	Use:   "remove-defaults",
+	Short: "Deactivate predefined users still using their default password",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		repo, err := openRepository()
+		if err != nil {
+			return err
+		}
+
+		removed, err := repo.RemoveDefaultUsers()
+		for _, username := range removed {
+			cmd.Printf("User %s deactivated\n", username)
+		}
+		if err != nil {
+			return err
+		}
+		if len(removed) == 0 {
+			cmd.Println("No predefined users with default password found")
+		}
+
+		return nil
+	},
+}
+
+func openRepository() (*repository.Repository, error) {
+	return repository.NewRepository(repository.RepositoryConfig{
+		Dsn:     viper.GetString(DATABASE_URL_FLAG),
+		Verbose: viper.GetBool(VERBOSE_FLAG),
+	})
+}
+
+func openRepositoryForUser(username string) (*repository.Repository, *model.User, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return nil, nil, err
+	}
+	user := model.User{}
+	if err := repo.UserByName(username, &user); err != nil {
+		return nil, nil, fmt.Errorf("unable to find user %s: %w", username, err)
+	}
+	return repo, &user, nil
+}
+
+func passwordFromFlagOrPrompt(cmd *cobra.Command) (string, error) {
+	password, err := cmd.Flags().GetString(PASSWORD_FLAG)
+	if err != nil {
+		return "", err
+	}
+	if password != "" {
+		return password, checkPassword(password)
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("no password supplied")
+	}
+
+	cmd.Print("Password: ")
+	first, err := term.ReadPassword(int(os.Stdin.Fd()))
+	cmd.Println()
+	if err != nil {
+		return "", err
+	}
+	cmd.Print("Repeat password: ")
+	second, err := term.ReadPassword(int(os.Stdin.Fd()))
+	cmd.Println()
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+	if len(first) == 0 {
+		return "", errors.New("no password supplied")
+	}
+
+	return string(first), checkPassword(string(first))
+}
+
+func checkPassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+func init() {
+	userCmd.AddCommand(userListCmd)
+	userCmd.AddCommand(userAddCmd)
+	userCmd.AddCommand(userLevelCmd)
+	userCmd.AddCommand(userPasswordCmd)
+	userCmd.AddCommand(userDeactivateCmd)
+	userCmd.AddCommand(userActivateCmd)
+	userCmd.AddCommand(userRemoveDefaultsCmd)
+
+	userAddCmd.Flags().String(PASSWORD_FLAG, "", "user password (prompted if not set)")
+	userAddCmd.Flags().String(LEVEL_FLAG, "regular", "user level (admin or regular)")
+	userPasswordCmd.Flags().String(PASSWORD_FLAG, "", "new password (prompted if not set)")
+
+	rootCmd.AddCommand(userCmd)
+}
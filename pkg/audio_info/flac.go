@@ -0,0 +1,47 @@
+package audio_info
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// probeFlac reads stream info block, which is first metadata block of
+// stream starting at start
+func probeFlac(r io.ReadSeeker, start int64) (*Info, error) {
+
+	// skip "fLaC" marker
+	if _, err := r.Seek(start+4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+	if header[0]&0x7F != 0 || length < 18 {
+		return nil, errors.New("flac stream info block not found")
+	}
+
+	block := make([]byte, 18)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, err
+	}
+
+	sampleRate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+	if sampleRate == 0 {
+		return nil, errors.New("invalid flac sample rate")
+	}
+	samples := int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+
+	info := Info{
+		Format:     FLAC,
+		SampleRate: int(sampleRate),
+		Channels:   int(block[12]>>1&0x07) + 1,
+		Duration:   time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second)),
+	}
+
+	return &info, nil
+}
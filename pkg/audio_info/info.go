@@ -0,0 +1,86 @@
+package audio_info
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+type Format string
+
+const (
+	MP3  Format = "mp3"
+	WAV  Format = "wav"
+	FLAC Format = "flac"
+	OGG  Format = "ogg"
+	MP4  Format = "mp4"
+)
+
+// Info holds technical properties of an audio stream, as measured from the
+// file content
+type Info struct {
+	Format     Format
+	Duration   time.Duration
+	SampleRate int
+	Channels   int
+	// Bitrate in bits per second, average for variable bitrate streams
+	Bitrate int
+}
+
+// Probe decodes stream headers and measures duration of supported formats
+func Probe(r io.ReadSeeker) (*Info, error) {
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return probeWav(r)
+	case bytes.Equal(header[0:4], []byte("fLaC")):
+		return probeFlac(r, 0)
+	case bytes.Equal(header[0:4], []byte("OggS")):
+		return probeOgg(r, size)
+	case bytes.Equal(header[4:8], []byte("ftyp")):
+		return probeMp4(r, size)
+	case bytes.Equal(header[0:3], []byte("ID3")):
+		// id3 tag is mostly found in mp3, but some tools prepend it to flac
+		start, err := id3v2Length(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = r.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		marker := make([]byte, 4)
+		if _, err = io.ReadFull(r, marker); err == nil && bytes.Equal(marker, []byte("fLaC")) {
+			return probeFlac(r, start)
+		}
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return probeMp3(r, size)
+	case isMpegFrameSync(header):
+		return probeMp3(r, size)
+	}
+
+	return nil, ErrUnsupportedFormat
+}
@@ -0,0 +1,263 @@
+package audio_info
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// maximum number of bytes skipped while looking for first or lost frame sync
+const maxResync = 64 * 1024
+
+var mpegBitrates = [2][3][15]int{
+	// MPEG 1, layers I, II, III
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	// MPEG 2 and 2.5, layers I, II, III
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mpegSampleRates = map[byte][3]int{
+	3: {44100, 48000, 32000}, // MPEG 1
+	2: {22050, 24000, 16000}, // MPEG 2
+	0: {11025, 12000, 8000},  // MPEG 2.5
+}
+
+type mpegFrame struct {
+	mpeg1      bool
+	layer      int
+	bitrate    int
+	sampleRate int
+	channels   int
+	samples    int
+	length     int
+}
+
+func isMpegFrameSync(h []byte) bool {
+	_, ok := parseMpegHeader(h)
+	return ok
+}
+
+func parseMpegHeader(h []byte) (mpegFrame, bool) {
+	f := mpegFrame{}
+	if len(h) < 4 || h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return f, false
+	}
+	version := (h[1] >> 3) & 0x03
+	layerBits := (h[1] >> 1) & 0x03
+	bitrateIndex := h[2] >> 4
+	sampleRateIndex := (h[2] >> 2) & 0x03
+	padding := int((h[2] >> 1) & 0x01)
+	channelMode := h[3] >> 6
+
+	if version == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return f, false
+	}
+
+	f.mpeg1 = version == 3
+	f.layer = 4 - int(layerBits)
+	table := 0
+	if !f.mpeg1 {
+		table = 1
+	}
+	f.bitrate = mpegBitrates[table][f.layer-1][bitrateIndex] * 1000
+	f.sampleRate = mpegSampleRates[version][sampleRateIndex]
+	f.channels = 2
+	if channelMode == 3 {
+		f.channels = 1
+	}
+
+	switch {
+	case f.layer == 1:
+		f.samples = 384
+		f.length = (12*f.bitrate/f.sampleRate + padding) * 4
+	case f.layer == 3 && !f.mpeg1:
+		f.samples = 576
+		f.length = 72*f.bitrate/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		f.length = 144*f.bitrate/f.sampleRate + padding
+	}
+
+	return f, f.length > 4
+}
+
+// sideInfoLength returns size of layer III side info, which precedes Xing header
+func (f mpegFrame) sideInfoLength() int {
+	switch {
+	case f.mpeg1 && f.channels == 1:
+		return 17
+	case f.mpeg1:
+		return 32
+	case f.channels == 1:
+		return 9
+	default:
+		return 17
+	}
+}
+
+func id3v2Length(r io.ReadSeeker) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(header[0:3], []byte("ID3")) {
+		return 0, nil
+	}
+	size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+	size += 10
+	if header[5]&0x10 != 0 {
+		// footer present
+		size += 10
+	}
+	return size, nil
+}
+
+func probeMp3(r io.ReadSeeker, size int64) (*Info, error) {
+
+	start, err := id3v2Length(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	first, skipped, err := findFirstFrame(br)
+	if err != nil {
+		return nil, err
+	}
+	start += int64(skipped)
+
+	info := Info{
+		Format:     MP3,
+		SampleRate: first.sampleRate,
+		Channels:   first.channels,
+	}
+
+	// VBR header in first frame carries total frame count
+	frameData, err := br.Peek(first.length)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if frames, streamBytes := vbrHeader(first, frameData); frames > 0 {
+		info.Duration = time.Duration(float64(frames) * float64(first.samples) / float64(first.sampleRate) * float64(time.Second))
+		if streamBytes == 0 {
+			streamBytes = size - start
+		}
+		if seconds := info.Duration.Seconds(); seconds > 0 {
+			info.Bitrate = int(float64(streamBytes) * 8 / seconds)
+		}
+		return &info, nil
+	}
+
+	// no VBR header, count frames
+	var samples, streamBytes int64
+	for {
+		header, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		frame, ok := parseMpegHeader(header)
+		if !ok || frame.sampleRate != first.sampleRate {
+			if isTrailingTag(br) {
+				break
+			}
+			if _, err := br.Discard(1); err != nil {
+				break
+			}
+			continue
+		}
+		discarded, err := br.Discard(frame.length)
+		streamBytes += int64(discarded)
+		if err != nil {
+			// truncated last frame
+			break
+		}
+		samples += int64(frame.samples)
+	}
+
+	if samples == 0 {
+		return nil, errors.New("no mpeg audio frames found")
+	}
+
+	info.Duration = time.Duration(float64(samples) / float64(first.sampleRate) * float64(time.Second))
+	info.Bitrate = int(float64(streamBytes) * 8 / info.Duration.Seconds())
+
+	return &info, nil
+}
+
+// findFirstFrame looks for frame sync followed by another valid frame, so
+// random sync-like bytes in garbage are not mistaken for audio
+func findFirstFrame(br *bufio.Reader) (mpegFrame, int, error) {
+	for skipped := 0; skipped < maxResync; skipped++ {
+		header, err := br.Peek(4)
+		if err != nil {
+			return mpegFrame{}, 0, errors.New("no mpeg audio frames found")
+		}
+		if frame, ok := parseMpegHeader(header); ok {
+			next, err := br.Peek(frame.length + 4)
+			if err != nil {
+				// single frame file
+				return frame, skipped, nil
+			}
+			if nextFrame, ok := parseMpegHeader(next[frame.length:]); ok && nextFrame.sampleRate == frame.sampleRate {
+				return frame, skipped, nil
+			}
+		}
+		if _, err := br.Discard(1); err != nil {
+			return mpegFrame{}, 0, err
+		}
+	}
+	return mpegFrame{}, 0, errors.New("no mpeg audio frames found")
+}
+
+// vbrHeader reads frame and byte counts from Xing / Info or VBRI headers
+func vbrHeader(f mpegFrame, data []byte) (int64, int64) {
+	xingOffset := 4 + f.sideInfoLength()
+	if len(data) >= xingOffset+16 {
+		tag := string(data[xingOffset : xingOffset+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(data[xingOffset+4:])
+			pos := xingOffset + 8
+			var frames, streamBytes int64
+			if flags&0x01 != 0 {
+				frames = int64(binary.BigEndian.Uint32(data[pos:]))
+				pos += 4
+			}
+			if flags&0x02 != 0 && len(data) >= pos+4 {
+				streamBytes = int64(binary.BigEndian.Uint32(data[pos:]))
+			}
+			return frames, streamBytes
+		}
+	}
+	const vbriOffset = 4 + 32
+	if len(data) >= vbriOffset+18 && string(data[vbriOffset:vbriOffset+4]) == "VBRI" {
+		streamBytes := int64(binary.BigEndian.Uint32(data[vbriOffset+10:]))
+		frames := int64(binary.BigEndian.Uint32(data[vbriOffset+14:]))
+		return frames, streamBytes
+	}
+	return 0, 0
+}
+
+func isTrailingTag(br *bufio.Reader) bool {
+	for _, tag := range []string{"TAG", "APETAGEX", "LYRICSBEGIN", "ID3"} {
+		data, err := br.Peek(len(tag))
+		if err == nil && string(data) == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package audio_info
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxMovieSize limits movie atom read into memory, larger ones mostly carry
+// embedded pictures
+const maxMovieSize = 16 << 20
+
+// MP4Atoms calls visit for every atom in b, children are not visited
+func MP4Atoms(b []byte, visit func(kind string, body []byte)) {
+	for len(b) >= 8 {
+		size := int64(binary.BigEndian.Uint32(b))
+		kind := string(b[4:8])
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			// atom extends to the end
+			size = int64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size = int64(binary.BigEndian.Uint64(b[8:]))
+			headerLen = 16
+		}
+		if size < headerLen || size > int64(len(b)) {
+			return
+		}
+		visit(kind, b[headerLen:size])
+		b = b[size:]
+	}
+}
+
+// ReadMP4Movie returns body of moov atom, skipping media data without
+// reading it
+func ReadMP4Movie(r io.ReadSeeker, size int64) ([]byte, error) {
+	offset, length, err := findMP4Atom(r, size, "moov")
+	if err != nil {
+		return nil, err
+	}
+	if length > maxMovieSize {
+		return nil, errors.New("mp4 movie atom too large")
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	movie := make([]byte, length)
+	if _, err := io.ReadFull(r, movie); err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
+
+// findMP4Atom returns offset and length of body of first top level atom of
+// given kind
+func findMP4Atom(r io.ReadSeeker, size int64, kind string) (int64, int64, error) {
+	offset := int64(0)
+	header := make([]byte, 16)
+	for offset+8 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, 0, err
+		}
+		atomSize := int64(binary.BigEndian.Uint32(header))
+		headerLen := int64(8)
+		switch atomSize {
+		case 0:
+			atomSize = size - offset
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return 0, 0, err
+			}
+			atomSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerLen = 16
+		}
+		if atomSize < headerLen {
+			break
+		}
+		if string(header[4:8]) == kind {
+			return offset + headerLen, atomSize - headerLen, nil
+		}
+		offset += atomSize
+	}
+	return 0, 0, fmt.Errorf("mp4 %s atom not found", kind)
+}
+
+// probeMp4 reads duration from movie header, and stream properties from
+// first audio sample description
+func probeMp4(r io.ReadSeeker, size int64) (*Info, error) {
+
+	movie, err := ReadMP4Movie(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := Info{Format: MP4}
+	var visit func(kind string, body []byte)
+	visit = func(kind string, body []byte) {
+		switch kind {
+		case "mvhd":
+			var timescale, duration int64
+			switch {
+			case len(body) >= 32 && body[0] == 1:
+				timescale = int64(binary.BigEndian.Uint32(body[20:]))
+				duration = int64(binary.BigEndian.Uint64(body[24:]))
+			case len(body) >= 20:
+				timescale = int64(binary.BigEndian.Uint32(body[12:]))
+				duration = int64(binary.BigEndian.Uint32(body[16:]))
+			}
+			if timescale > 0 {
+				info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+			}
+		case "trak", "mdia", "minf", "stbl":
+			MP4Atoms(body, visit)
+		case "stsd":
+			// full atom with entry count, followed by sample entries
+			if len(body) > 8 {
+				MP4Atoms(body[8:], visit)
+			}
+		case "mp4a", "alac":
+			if info.SampleRate == 0 && len(body) >= 28 {
+				info.Channels = int(binary.BigEndian.Uint16(body[16:]))
+				info.SampleRate = int(binary.BigEndian.Uint32(body[24:]) >> 16)
+			}
+		}
+	}
+	MP4Atoms(movie, visit)
+
+	if info.SampleRate == 0 {
+		return nil, errors.New("mp4 file has no audio track")
+	}
+
+	return &info, nil
+}
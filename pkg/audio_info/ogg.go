@@ -0,0 +1,71 @@
+package audio_info
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// probeOgg reads vorbis or opus identification header from first page, and
+// duration from granule position of last page
+func probeOgg(r io.ReadSeeker, size int64) (*Info, error) {
+
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return nil, err
+	}
+	// identification header is alone on first page
+	length := 0
+	for _, segment := range segments {
+		length += int(segment)
+	}
+	ident := make([]byte, length)
+	if _, err := io.ReadFull(r, ident); err != nil {
+		return nil, err
+	}
+
+	info := Info{Format: OGG}
+	var preSkip int64
+	switch {
+	case len(ident) >= 16 && string(ident[:7]) == "\x01vorbis":
+		info.Channels = int(ident[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(ident[12:16]))
+	case len(ident) >= 12 && string(ident[:8]) == "OpusHead":
+		// opus granule position always counts 48 kHz samples
+		info.Channels = int(ident[9])
+		info.SampleRate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(ident[10:12]))
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if info.SampleRate == 0 {
+		return nil, errors.New("invalid ogg sample rate")
+	}
+
+	tail := int64(64 * 1024)
+	if tail > size {
+		tail = size
+	}
+	if _, err := r.Seek(size-tail, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, tail)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	last := bytes.LastIndex(buf, []byte("OggS"))
+	if last < 0 || last+14 > len(buf) {
+		return nil, errors.New("ogg last page not found")
+	}
+	if granule := int64(binary.LittleEndian.Uint64(buf[last+6:])) - preSkip; granule > 0 {
+		info.Duration = time.Duration(float64(granule) / float64(info.SampleRate) * float64(time.Second))
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,153 @@
+package audio_info
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Payload returns start and end offset of encoded audio in stream, leaving
+// out tags and metadata around it: id3 tags of mp3 and flac, flac metadata
+// blocks, ogg header pages, chunks other than wav data and atoms other than
+// mp4 media data. Unknown formats are taken as a whole.
+func Payload(r io.ReadSeeker) (int64, int64, error) {
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return 0, size, nil
+		}
+		return 0, 0, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	start, end := int64(0), size
+	switch {
+	case bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return wavPayload(r, size)
+	case bytes.Equal(header[0:4], []byte("OggS")):
+		return oggPayload(r, size)
+	case bytes.Equal(header[4:8], []byte("ftyp")):
+		offset, length, err := findMP4Atom(r, size, "mdat")
+		if err != nil {
+			return 0, 0, err
+		}
+		return offset, clamp(offset+length, size), nil
+	case bytes.Equal(header[0:3], []byte("ID3")):
+		if start, err = id3v2Length(r); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// flac may be preceded by id3 tag, mp3 is found between id3 tags
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	marker := make([]byte, 4)
+	if _, err = io.ReadFull(r, marker); err == nil && bytes.Equal(marker, []byte("fLaC")) {
+		if start, err = flacFrames(r, start); err != nil {
+			return 0, 0, err
+		}
+	}
+	if size >= 128 {
+		if _, err = r.Seek(size-128, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err = io.ReadFull(r, marker[:3]); err == nil && bytes.Equal(marker[:3], []byte("TAG")) {
+			end = size - 128
+		}
+	}
+	if start > end {
+		start = end
+	}
+
+	return start, end, nil
+}
+
+// flacFrames returns offset of first frame, following metadata blocks of
+// stream starting at start
+func flacFrames(r io.ReadSeeker, start int64) (int64, error) {
+	offset := start + 4
+	header := make([]byte, 4)
+	for {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, err
+		}
+		offset += 4 + (int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3]))
+		if header[0]&0x80 != 0 {
+			return offset, nil
+		}
+	}
+}
+
+func wavPayload(r io.ReadSeeker, size int64) (int64, int64, error) {
+	offset := int64(12)
+	chunkHeader := make([]byte, 8)
+	for offset+8 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			return 0, 0, err
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		if string(chunkHeader[0:4]) == "data" {
+			return offset + 8, clamp(offset+8+chunkSize, size), nil
+		}
+		// chunks are word aligned
+		offset += 8 + chunkSize + chunkSize%2
+	}
+	return 0, 0, errors.New("wav file has no data chunk")
+}
+
+// oggPayload returns offset of first page with audio, header pages have
+// granule position zero. Audio pages keep page headers, which change when
+// tags grow or shrink to another number of header pages.
+func oggPayload(r io.ReadSeeker, size int64) (int64, int64, error) {
+	offset := int64(0)
+	header := make([]byte, 27)
+	for offset+27 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, 0, err
+		}
+		if string(header[:4]) != "OggS" {
+			return 0, 0, errors.New("ogg page not found")
+		}
+		if binary.LittleEndian.Uint64(header[6:14]) != 0 {
+			return offset, size, nil
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return 0, 0, err
+		}
+		offset += 27 + int64(len(segments))
+		for _, segment := range segments {
+			offset += int64(segment)
+		}
+	}
+	return size, size, nil
+}
+
+func clamp(offset, size int64) int64 {
+	if offset > size {
+		return size
+	}
+	return offset
+}
@@ -0,0 +1,65 @@
+package audio_info
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+func probeWav(r io.ReadSeeker) (*Info, error) {
+
+	// skip RIFF header, chunks follow
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	info := Info{Format: WAV}
+	byteRate := 0
+	haveFormat := false
+
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("invalid wav format chunk")
+			}
+			fmtChunk := make([]byte, 16)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return nil, err
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			byteRate = int(binary.LittleEndian.Uint32(fmtChunk[8:12]))
+			info.Bitrate = byteRate * 8
+			haveFormat = true
+			size -= 16
+		case "data":
+			if !haveFormat || byteRate == 0 {
+				return nil, errors.New("wav data chunk found before format chunk")
+			}
+			info.Duration = time.Duration(float64(size) / float64(byteRate) * float64(time.Second))
+			return &info, nil
+		}
+
+		// chunks are word aligned
+		if size%2 == 1 {
+			size++
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("wav file has no data chunk")
+}
@@ -0,0 +1,234 @@
+package audio_index
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ozz-ms/pkg/data/model"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var DefaultIndexName = "recordings.bl"
+
+const (
+	documentType = "recording"
+	analyzerName = "recording"
+	batchSize    = 1000
+)
+
+// searchFields are indexed recording fields, with boost of matches in them
+var searchFields = map[string]float64{
+	"name":     3,
+	"client":   2,
+	"category": 1.5,
+	"comment":  1,
+}
+
+type document struct {
+	Name     string `json:"name"`
+	Client   string `json:"client"`
+	Comment  string `json:"comment"`
+	Category string `json:"category"`
+}
+
+func (d document) Type() string {
+	return documentType
+}
+
+func newDocument(rec model.AudioRecording) document {
+	doc := document{
+		Name:     rec.Name,
+		Client:   rec.Client.DisplayName(),
+		Category: rec.Category.Name,
+	}
+	if rec.Comment != nil {
+		doc.Comment = *rec.Comment
+	}
+	return doc
+}
+
+func createIndexMapping() (mapping.IndexMapping, error) {
+
+	indexMapping := bleve.NewIndexMapping()
+
+	// unlike "standard", keeps stop words, recording names are short and
+	// not english
+	if err := indexMapping.AddCustomAnalyzer(analyzerName, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name},
+	}); err != nil {
+		return nil, err
+	}
+
+	textFieldMapping := bleve.NewTextFieldMapping()
+	textFieldMapping.Analyzer = analyzerName
+	textFieldMapping.Store = true
+
+	recordingMapping := bleve.NewDocumentMapping()
+	for field := range searchFields {
+		recordingMapping.AddFieldMappingsAt(field, textFieldMapping)
+	}
+
+	indexMapping.AddDocumentMapping(documentType, recordingMapping)
+	indexMapping.DefaultAnalyzer = analyzerName
+
+	return indexMapping, nil
+}
+
+// RecordingIndex is full text index of recording name, client, comment and
+// category. Database stays the source of truth, index keeps only what is
+// searched and highlighted.
+type RecordingIndex struct {
+	index bleve.Index
+}
+
+// Open opens existing index, or creates a new empty one
+func Open(path string) (*RecordingIndex, error) {
+	idx, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		indexMapping, err := createIndexMapping()
+		if err != nil {
+			return nil, err
+		}
+		idx, err = bleve.New(path, indexMapping)
+		if err != nil {
+			return nil, err
+		}
+		return &RecordingIndex{index: idx}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open recording index %s: %w", path, err)
+	}
+	return &RecordingIndex{index: idx}, nil
+}
+
+func (i *RecordingIndex) Close() error {
+	return i.index.Close()
+}
+
+func (i *RecordingIndex) DocCount() (uint64, error) {
+	return i.index.DocCount()
+}
+
+func (i *RecordingIndex) Index(rec model.AudioRecording) error {
+	return i.index.Index(docID(rec.ID), newDocument(rec))
+}
+
+func (i *RecordingIndex) Delete(id uint) error {
+	return i.index.Delete(docID(id))
+}
+
+// Rebuild replaces index content with given recordings
+func (i *RecordingIndex) Rebuild(recordings []model.AudioRecording) error {
+
+	keep := map[string]bool{}
+	batch := i.index.NewBatch()
+	for _, rec := range recordings {
+		id := docID(rec.ID)
+		keep[id] = true
+		if err := batch.Index(id, newDocument(rec)); err != nil {
+			return err
+		}
+		if batch.Size() >= batchSize {
+			if err := i.index.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+
+	// drop documents of recordings which are gone
+	count, err := i.index.DocCount()
+	if err != nil {
+		return err
+	}
+	res, err := i.index.Search(bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false))
+	if err != nil {
+		return err
+	}
+	for _, hit := range res.Hits {
+		if !keep[hit.ID] {
+			batch.Delete(hit.ID)
+		}
+	}
+
+	return i.index.Batch(batch)
+}
+
+type Hit struct {
+	ID    uint
+	Score float64
+	// Highlights are matching fragments per field, matches wrapped in <mark>
+	Highlights map[string][]string
+}
+
+type Results struct {
+	Total uint64
+	Hits  []Hit
+}
+
+// Search finds recordings containing all words of the query text. Words
+// match whole terms, or term prefixes with lower score, in any indexed field.
+func (i *RecordingIndex) Search(text string, from, size int) (*Results, error) {
+
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return &Results{Hits: []Hit{}}, nil
+	}
+
+	conjuncts := []query.Query{}
+	for _, word := range words {
+		disjuncts := []query.Query{}
+		for field, boost := range searchFields {
+			match := bleve.NewMatchQuery(word)
+			match.SetField(field)
+			match.SetBoost(boost * 2)
+			prefix := bleve.NewPrefixQuery(word)
+			prefix.SetField(field)
+			prefix.SetBoost(boost)
+			disjuncts = append(disjuncts, match, prefix)
+		}
+		conjuncts = append(conjuncts, bleve.NewDisjunctionQuery(disjuncts...))
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), size, from, false)
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+
+	res, err := i.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	results := Results{Total: res.Total, Hits: []Hit{}}
+	for _, hit := range res.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		highlights := hit.Fragments
+		if highlights == nil {
+			highlights = map[string][]string{}
+		}
+		results.Hits = append(results.Hits, Hit{
+			ID:         uint(id),
+			Score:      hit.Score,
+			Highlights: highlights,
+		})
+	}
+
+	return &results, nil
+}
+
+func docID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -18,18 +18,60 @@ type AudioRecordingDTO struct {
 	Path     string
 	Category string
 	Client   string
+	ClientID *uint
 	Comment  string
 	Active   bool
 	Duration time.Duration
 	Date     time.Time
+
+	SampleRate int
+	Channels   int
+	Bitrate    int
+	Checksum   string
+	Version    int
+	ValidFrom  *time.Time
+	ValidTo    *time.Time
+}
+
+type AudioRecordingVersionDTO struct {
+	Version    int
+	Path       string
+	Duration   time.Duration
+	SampleRate int
+	Channels   int
+	Bitrate    int
+	Checksum   string
+	UploadedAt time.Time
+	UploadedBy string
+	Current    bool
+}
+
+type AudioRecordingCreatedDTO struct {
+	AudioRecordingDTO
+	Warnings []string `json:",omitempty"`
+}
+
+type DuplicateAudioRecordingDTO struct {
+	Message  string            `json:"message"`
+	Existing AudioRecordingDTO `json:"existing"`
+}
+
+type DuplicateAudioRecordingsDTO struct {
+	Checksum   string
+	Recordings []AudioRecordingDTO
 }
 
 type AudioRecordingUpdateDTO struct {
 	Name     string `validate:"string"`
 	Category string `validate:"string"`
-	Client   string `validate:"string"`
+	// ClientID is kept when omitted, zero unlinks recording from its client
+	ClientID *uint  `validate:"int"`
 	Comment  string `validate:"string"`
 	Active   bool   `validate:"bool"`
+	// validity window dates, YYYY-MM-DD, empty for open end, omitted to keep
+	// current one
+	ValidFrom *string `validate:"date"`
+	ValidTo   *string `validate:"date"`
 }
 
 type PagedResults struct {
@@ -39,6 +81,26 @@ type PagedResults struct {
 type AudioRecordingsPagedResults struct {
 	PagedResults
 	Data []AudioRecordingDTO `json:"data"`
+	// Next is cursor of the following page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+type AudioRecordingTextSearchParams struct {
+	Query string `validate:"required" query:"q"`
+	Skip  *int   `validate:"int" query:"skip"`
+	Count *int   `validate:"int" query:"count"`
+}
+
+type AudioRecordingHitDTO struct {
+	AudioRecordingDTO
+	Score float64
+	// Highlights are matching fragments per field, matches wrapped in <mark>
+	Highlights map[string][]string
+}
+
+type AudioRecordingSearchResultsDTO struct {
+	PagedResults
+	Data []AudioRecordingHitDTO `json:"data"`
 }
 
 type DispositionDTO struct {
@@ -53,6 +115,7 @@ type DispositionDTO struct {
 
 type NewScheduleDTO struct {
 	ID        uint
+	Campaign  int    `validate:"required|int"`
 	Recording int    `validate:"required|int"`
 	Date      string `validate:"required|date"`
 	//Duration       string `validate:"required"`
@@ -66,6 +129,7 @@ type NewScheduleDTO struct {
 
 type ScheduleDTO struct {
 	ID                                                     uint
+	CampaignID                                             *uint
 	Recording                                              AudioRecordingDTO
 	Date                                                   time.Time
 	Duration                                               time.Duration
@@ -83,19 +147,100 @@ type AudioRecordingsSearchParams struct {
 	ToDate   *string `validate:"date" query:"toDate"`
 	Active   *bool   `validate:"bool" query:"active"`
 	Name     *string `validate:"string" query:"name"`
+	Client   *int    `validate:"int" query:"client"`
 	Sort     *string `validate:"string" query:"sort"`
 	Skip     *int    `validate:"int" query:"skip"`
 	Count    *int    `validate:"int" query:"count"`
+	// Cursor continues listing after the last recording of previous page
+	Cursor *string `validate:"string" query:"cursor"`
+	// ValidOn returns recordings which can be played on given date
+	ValidOn *string `validate:"date" query:"validOn"`
+	// Expired returns recordings whose validity window ended before today
+	Expired *bool `validate:"bool" query:"expired"`
 }
 
 type ScheduleSearchParams struct {
 	Recording *int `validate:"int" query:"recording"`
+	Campaign  *int `validate:"int" query:"campaign"`
 	//Category  *int    `validate:"int" query:"category"`
 	//Active   *bool   `validate:"bool" query:"active"`
 	FromDate *string `validate:"date" query:"fromDate"`
 	ToDate   *string `validate:"date" query:"toDate"`
 }
 
+type ClientDTO struct {
+	ID            uint
+	Name          string `validate:"required"`
+	ContactPerson string `validate:"string"`
+	Email         string `validate:"email"`
+	Phone         string `validate:"string"`
+	Address       string `validate:"string"`
+	TaxID         string `validate:"string"`
+	Notes         string `validate:"string"`
+}
+
+type ClientMergeDTO struct {
+	// Sources are merged into client from path and removed
+	Sources []uint `validate:"required|minLen:1"`
+}
+
+// ClientDuplicatesDTO groups clients whose names differ only in case,
+// punctuation or legal form
+type ClientDuplicatesDTO struct {
+	Key     string
+	Clients []ClientDTO
+}
+
+type SpotsDTO struct {
+	Shift1, Shift2, Shift3, Shift4 int
+	Total                          int
+}
+
+type CampaignDTO struct {
+	ID         uint
+	Name       string
+	ClientID   *uint
+	Client     string
+	StartDate  time.Time
+	EndDate    time.Time
+	Spots      SpotsDTO
+	Price      float64
+	Notes      string
+	Recordings []AudioRecordingDTO
+}
+
+type NewCampaignDTO struct {
+	Name      string `validate:"required"`
+	Client    uint   `validate:"required|int"`
+	StartDate string `validate:"required|date"`
+	EndDate   string `validate:"required|date"`
+	// spots sold per shift, or in total when shift is not specified
+	Spots1     int     `validate:"int|min:0"`
+	Spots2     int     `validate:"int|min:0"`
+	Spots3     int     `validate:"int|min:0"`
+	Spots4     int     `validate:"int|min:0"`
+	SpotsTotal int     `validate:"int|min:0"`
+	Price      float64 `validate:"float|min:0"`
+	Notes      string  `validate:"string"`
+	Recordings []uint
+}
+
+type CampaignSearchParams struct {
+	Client *int `validate:"int" query:"client"`
+	// ActiveOn returns campaigns running on given date
+	ActiveOn *string `validate:"date" query:"activeOn"`
+}
+
+// CampaignStatusDTO compares spots sold with spots scheduled and aired
+type CampaignStatusDTO struct {
+	Campaign  CampaignDTO
+	Sold      SpotsDTO
+	Scheduled SpotsDTO
+	Aired     SpotsDTO
+	// Unscheduled is number of sold spots not scheduled yet
+	Unscheduled int
+}
+
 type EqualizerDTO struct {
 	ID                                                          uint
 	Name                                                        string  `validate:"required"`
@@ -104,13 +249,15 @@ type EqualizerDTO struct {
 }
 
 type AudioRecordingCreateData struct {
-	Name     *string               `form:"name" validate:"required"`
-	Client   *string               `form:"name"`
-	Comment  *string               `form:"comment"`
-	Category *string               `form:"category" validate:"required"`
-	Duration *string               `form:"duration" validate:"required"`
-	Active   *string               `form:"active" validate:"required|bool"`
-	File     *multipart.FileHeader `form:"file" validate:"required"`
+	Name      *string               `form:"name" validate:"required"`
+	ClientID  *string               `form:"clientId"`
+	Comment   *string               `form:"comment"`
+	Category  *string               `form:"category" validate:"required"`
+	Duration  *string               `form:"duration"`
+	Active    *string               `form:"active" validate:"required|bool"`
+	ValidFrom *string               `form:"validFrom" validate:"date"`
+	ValidTo   *string               `form:"validTo" validate:"date"`
+	File      *multipart.FileHeader `form:"file" validate:"required"`
 }
 
 type ActiveAudioRecordsForCategorySearchParams struct {
@@ -148,3 +295,45 @@ type AudioRecordingLog struct {
 	Shift        int
 	ScheduleDate time.Time
 }
+
+type LoginDTO struct {
+	Username string `validate:"required" json:"username"`
+	Password string `validate:"required" json:"password"`
+}
+
+type UserDTO struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Level    int    `json:"level"`
+	Active   bool   `json:"active"`
+}
+
+type NewUserDTO struct {
+	Username string `validate:"required" json:"username"`
+	Password string `validate:"required|minLen:6" json:"password"`
+	Level    int    `validate:"int|min:0|max:1" json:"level"`
+}
+
+type UserLevelDTO struct {
+	Level int `validate:"int|min:0|max:1" json:"level"`
+}
+
+type UserPasswordDTO struct {
+	Password string `validate:"required|minLen:6" json:"password"`
+}
+
+type ChangePasswordDTO struct {
+	OldPassword string `validate:"required" json:"oldPassword"`
+	Password    string `validate:"required|minLen:6" json:"password"`
+}
+
+type TokenDTO struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      UserDTO   `json:"user"`
+}
+
+type PurgedAudioRecordingDTO struct {
+	RemovedFiles []string
+	FailedFiles  []string `json:",omitempty"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -44,12 +45,67 @@ type AudioRecording struct {
 	Name       string        `validate:"required" gorm:"index:one_name,unique,where:deleted_at is null"`
 	Path       string        `validate:"required"`
 	Duration   time.Duration `validate:"required"`
-	Client     *string       `validate:"-"`
-	Comment    *string       `validate:"-"`
+	ClientID   *uint
+	Client     *Client
+	Comment    *string `validate:"-"`
 	Active     bool
 	CategoryID int
 	Category   Category
 	Date       time.Time
+	SampleRate int
+	Channels   int
+	Bitrate    int
+	Checksum   string `gorm:"index;size:64"`
+	Size       int64
+	Version    int
+	// ValidFrom and ValidTo limit days recording can be scheduled and played
+	// at, both are inclusive and optional
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+// ValidOn checks if date falls into recording validity window
+func (r AudioRecording) ValidOn(date time.Time) bool {
+	if r.ValidFrom != nil && date.Before(*r.ValidFrom) {
+		return false
+	}
+	if r.ValidTo != nil && date.After(*r.ValidTo) {
+		return false
+	}
+	return true
+}
+
+var ErrInvalidValidity = errors.New("validity window ends before it starts")
+
+// SetValidity sets validity window from YYYY-MM-DD dates, nil or empty date
+// leaves that end of the window open
+func (r *AudioRecording) SetValidity(from, to *string) error {
+	parse := func(date *string) (*time.Time, error) {
+		if date == nil || *date == "" {
+			return nil, nil
+		}
+		d, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			return nil, err
+		}
+		return &d, nil
+	}
+
+	validFrom, err := parse(from)
+	if err != nil {
+		return err
+	}
+	validTo, err := parse(to)
+	if err != nil {
+		return err
+	}
+	if validFrom != nil && validTo != nil && validTo.Before(*validFrom) {
+		return ErrInvalidValidity
+	}
+
+	r.ValidFrom = validFrom
+	r.ValidTo = validTo
+	return nil
 }
 
 func (r AudioRecording) Map() AudioRecordingDTO {
@@ -58,14 +114,94 @@ func (r AudioRecording) Map() AudioRecordingDTO {
 		Name:     r.Name,
 		Path:     r.Path,
 		Category: r.Category.Name,
-		Client:   *r.Client,
+		Client:   r.Client.DisplayName(),
+		ClientID: r.ClientID,
 		Comment:  *r.Comment,
 		Active:   r.Active,
 		Duration: r.Duration,
 		Date:     r.Date,
+
+		SampleRate: r.SampleRate,
+		Channels:   r.Channels,
+		Bitrate:    r.Bitrate,
+		Checksum:   r.Checksum,
+		Version:    r.Version,
+		ValidFrom:  r.ValidFrom,
+		ValidTo:    r.ValidTo,
+	}
+}
+
+// Client is an advertiser whose recordings are aired
+type Client struct {
+	gorm.Model
+	Name          string `gorm:"index:one_client_name,unique,where:deleted_at is null;size:191"`
+	ContactPerson string
+	Email         string
+	Phone         string
+	Address       string
+	TaxID         string
+	Notes         string
+}
+
+// DisplayName returns client name, or empty string for recordings without
+// client
+func (c *Client) DisplayName() string {
+	if c == nil {
+		return ""
+	}
+	return c.Name
+}
+
+func (c Client) Map() ClientDTO {
+	return ClientDTO{
+		ID:            c.ID,
+		Name:          c.Name,
+		ContactPerson: c.ContactPerson,
+		Email:         c.Email,
+		Phone:         c.Phone,
+		Address:       c.Address,
+		TaxID:         c.TaxID,
+		Notes:         c.Notes,
 	}
 }
 
+// AudioRecordingVersion keeps every file uploaded for a recording, so a
+// replaced file can be restored
+type AudioRecordingVersion struct {
+	gorm.Model
+	RecordingID int
+	Recording   AudioRecording `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Version     int
+	Path        string
+	Duration    time.Duration
+	SampleRate  int
+	Channels    int
+	Bitrate     int
+	Checksum    string
+	Size        int64
+	UploadedAt  time.Time
+	UserID      *uint
+	User        *User
+}
+
+func (v AudioRecordingVersion) Map() AudioRecordingVersionDTO {
+	dto := AudioRecordingVersionDTO{
+		Version:    v.Version,
+		Path:       v.Path,
+		Duration:   v.Duration,
+		SampleRate: v.SampleRate,
+		Channels:   v.Channels,
+		Bitrate:    v.Bitrate,
+		Checksum:   v.Checksum,
+		UploadedAt: v.UploadedAt,
+		Current:    v.Version == v.Recording.Version,
+	}
+	if v.User != nil {
+		dto.UploadedBy = v.User.Username
+	}
+	return dto
+}
+
 type UserLevel int
 
 const (
@@ -73,11 +209,32 @@ const (
 	Admin
 )
 
+func (l UserLevel) String() string {
+	switch l {
+	case Admin:
+		return "admin"
+	case Regular:
+		return "regular"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+func ParseUserLevel(level string) (UserLevel, error) {
+	switch level {
+	case "admin":
+		return Admin, nil
+	case "regular":
+		return Regular, nil
+	}
+	return Regular, fmt.Errorf("unknown user level: %s", level)
+}
+
 type User struct {
 	gorm.Model
-	Username string
+	Username string `gorm:"uniqueIndex;size:191"`
 	Password string
 	Level    UserLevel
+	Active   bool `gorm:"default:true"`
 }
 
 func (u *User) SetPassword(password string) error {
@@ -91,8 +248,106 @@ func (u *User) SetPassword(password string) error {
 	return nil
 }
 
+type Users []User
+
+func (u Users) Print() {
+	table := uitable.New()
+	table.AddRow("ID", "USERNAME", "LEVEL", "ACTIVE")
+	for _, v := range u {
+		table.AddRow(v.ID, v.Username, v.Level, v.Active)
+	}
+	fmt.Println(table)
+}
+
+func (u User) Map() UserDTO {
+	return UserDTO{
+		ID:       u.ID,
+		Username: u.Username,
+		Level:    int(u.Level),
+		Active:   u.Active,
+	}
+}
+
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+type Session struct {
+	gorm.Model
+	Token     string `gorm:"uniqueIndex;size:64"`
+	UserID    uint
+	User      User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+func (s Session) Valid(now time.Time) bool {
+	return !s.Revoked && now.Before(s.ExpiresAt)
+}
+
+// Campaign is a sales order: spots sold to a client for a date range, aired
+// using campaign recordings
+type Campaign struct {
+	gorm.Model
+	Name       string `gorm:"index:one_campaign_name,unique,where:deleted_at is null;size:191"`
+	ClientID   *uint
+	Client     *Client
+	StartDate  time.Time
+	EndDate    time.Time
+	Spots1     int
+	Spots2     int
+	Spots3     int
+	Spots4     int
+	SpotsTotal int
+	Price      float64
+	Notes      string
+	Recordings []AudioRecording `gorm:"many2many:campaign_recordings;"`
+}
+
+// SoldSpots returns purchased spots per shift and in total. Total defaults to
+// sum of per shift spots, when campaign is sold by shift only.
+func (c Campaign) SoldSpots() SpotsDTO {
+	spots := SpotsDTO{
+		Shift1: c.Spots1,
+		Shift2: c.Spots2,
+		Shift3: c.Spots3,
+		Shift4: c.Spots4,
+		Total:  c.SpotsTotal,
+	}
+	if spots.Total == 0 {
+		spots.Total = spots.Shift1 + spots.Shift2 + spots.Shift3 + spots.Shift4
+	}
+	return spots
+}
+
+// Includes checks if date falls into campaign date range
+func (c Campaign) Includes(date time.Time) bool {
+	return !date.Before(c.StartDate) && !date.After(c.EndDate)
+}
+
+func (c Campaign) Map() CampaignDTO {
+	dto := CampaignDTO{
+		ID:         c.ID,
+		Name:       c.Name,
+		ClientID:   c.ClientID,
+		Client:     c.Client.DisplayName(),
+		StartDate:  c.StartDate,
+		EndDate:    c.EndDate,
+		Spots:      c.SoldSpots(),
+		Price:      c.Price,
+		Notes:      c.Notes,
+		Recordings: []AudioRecordingDTO{},
+	}
+	for _, rec := range c.Recordings {
+		dto.Recordings = append(dto.Recordings, rec.Map())
+	}
+	return dto
+}
+
 type Schedule struct {
 	gorm.Model
+	CampaignID   *uint
+	Campaign     *Campaign
 	RecordingID  int
 	Recording    AudioRecording `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Date         time.Time
@@ -114,7 +369,8 @@ type Schedule struct {
 func (s Schedule) Map() ScheduleDTO {
 
 	dto := ScheduleDTO{
-		ID: s.ID,
+		ID:         s.ID,
+		CampaignID: s.CampaignID,
 		Recording: AudioRecordingDTO{
 			ID:       s.Recording.ID,
 			Name:     s.Recording.Name,
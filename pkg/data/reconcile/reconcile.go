@@ -0,0 +1,283 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ozz-ms/pkg/audio_info"
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+	"ozz-ms/pkg/util"
+
+	"github.com/gosuri/uitable"
+	"github.com/h2non/filetype"
+)
+
+type Options struct {
+	Root string
+	// SkipHash compares file sizes only
+	SkipHash bool
+	// UpdateMissing stores checksum and size of recordings uploaded before
+	// they were recorded
+	UpdateMissing bool
+	// Exclude is skipped while looking for orphans, e.g. quarantine folder
+	Exclude string
+}
+
+type ChangedFile struct {
+	Recording model.AudioRecording
+	Reason    string
+}
+
+type Report struct {
+	Checked int
+	Missing []model.AudioRecording
+	Changed []ChangedFile
+	// Orphans are paths, relative to root, not referenced by any recording
+	Orphans []string
+	// Updated recordings got checksum and size recorded
+	Updated []model.AudioRecording
+}
+
+func (r Report) Print() {
+	table := uitable.New()
+	table.MaxColWidth = 120
+	table.Wrap = true
+	for _, rec := range r.Missing {
+		table.AddRow("MISSING", rec.ID, rec.Name, rec.Path)
+	}
+	for _, ch := range r.Changed {
+		table.AddRow("CHANGED", ch.Recording.ID, ch.Recording.Name, ch.Recording.Path, ch.Reason)
+	}
+	for _, path := range r.Orphans {
+		table.AddRow("ORPHAN", "", "", path)
+	}
+	for _, rec := range r.Updated {
+		table.AddRow("UPDATED", rec.ID, rec.Name, rec.Path)
+	}
+	if len(table.Rows) > 0 {
+		fmt.Println(table)
+	}
+	fmt.Printf("Checked: %d, missing: %d, changed: %d, orphans: %d\n", r.Checked, len(r.Missing), len(r.Changed), len(r.Orphans))
+}
+
+// Check compares recordings with files under media root
+func Check(repo *repository.Repository, opts Options) (*Report, error) {
+
+	report := Report{}
+
+	recordings := []model.AudioRecording{}
+	if err := repo.AllAudioRecordings(&recordings); err != nil {
+		return nil, err
+	}
+
+	for _, rec := range recordings {
+		report.Checked++
+		fullPath := filepath.Join(opts.Root, rec.Path)
+		stat, err := os.Stat(fullPath)
+		if err != nil || stat.IsDir() {
+			report.Missing = append(report.Missing, rec)
+			continue
+		}
+
+		if rec.Size != 0 && stat.Size() != rec.Size {
+			report.Changed = append(report.Changed, ChangedFile{
+				Recording: rec,
+				Reason:    fmt.Sprintf("size changed from %d to %d", rec.Size, stat.Size()),
+			})
+			continue
+		}
+
+		if opts.SkipHash && !(opts.UpdateMissing && rec.Checksum == "") {
+			continue
+		}
+
+		checksum, err := util.FileChecksum(fullPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.Checksum == "" {
+			if opts.UpdateMissing {
+				if err := repo.SetAudioRecordingFileInfo(rec.ID, checksum, stat.Size()); err != nil {
+					return nil, err
+				}
+				report.Updated = append(report.Updated, rec)
+			}
+			continue
+		}
+		if checksum != rec.Checksum {
+			report.Changed = append(report.Changed, ChangedFile{
+				Recording: rec,
+				Reason:    "content checksum changed",
+			})
+		}
+	}
+
+	referencedPaths, err := repo.ReferencedAudioPaths()
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for _, path := range referencedPaths {
+		referenced[filepath.Clean(path)] = true
+	}
+
+	var exclude string
+	if opts.Exclude != "" {
+		if exclude, err = filepath.Abs(opts.Exclude); err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.WalkDir(opts.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if exclude != "" {
+				if abs, err := filepath.Abs(path); err == nil && abs == exclude {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(opts.Root, path)
+		if err != nil {
+			return err
+		}
+		if !referenced[rel] {
+			report.Orphans = append(report.Orphans, rel)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ImportOrphans creates inactive recordings for orphaned audio files. Category
+// is matched by top level folder. Files which cannot be imported are returned
+// with the reason.
+func ImportOrphans(repo *repository.Repository, root string, orphans []string) ([]model.AudioRecording, map[string]error) {
+
+	imported := []model.AudioRecording{}
+	skipped := map[string]error{}
+
+	for _, orphan := range orphans {
+		rec, err := importOrphan(repo, root, orphan)
+		if err != nil {
+			skipped[orphan] = err
+			continue
+		}
+		imported = append(imported, *rec)
+	}
+
+	return imported, skipped
+}
+
+func importOrphan(repo *repository.Repository, root, orphan string) (*model.AudioRecording, error) {
+
+	folder := strings.SplitN(filepath.ToSlash(orphan), "/", 2)[0]
+	if folder == filepath.ToSlash(orphan) {
+		return nil, errors.New("file is not in category folder")
+	}
+	cat := model.Category{}
+	if err := repo.CategoryByPath(folder, &cat); err != nil {
+		return nil, fmt.Errorf("no category for folder %s", folder)
+	}
+
+	fullPath := filepath.Join(root, orphan)
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 261)
+	n, _ := io.ReadFull(f, header)
+	if !filetype.IsAudio(header[:n]) {
+		return nil, errors.New("not an audio file")
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	info, err := audio_info.Probe(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to measure duration: %w", err)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	checksum, err := util.Checksum(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// recording names are unique, fall back to relative path
+	base := filepath.Base(orphan)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	exists, err := repo.AudioRecordingNameExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		name = strings.TrimSuffix(filepath.ToSlash(orphan), filepath.Ext(orphan))
+	}
+
+	comment := "imported by media check"
+	rec := model.AudioRecording{
+		Name:       name,
+		Path:       orphan,
+		Duration:   info.Duration,
+		SampleRate: info.SampleRate,
+		Channels:   info.Channels,
+		Bitrate:    info.Bitrate,
+		Checksum:   checksum,
+		Size:       stat.Size(),
+		Comment:    &comment,
+		Active:     false,
+		CategoryID: int(cat.ID),
+		Date:       stat.ModTime(),
+	}
+
+	if err := repo.NewAudioRecording(&rec, 0); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// QuarantineOrphans moves orphaned files out of media root, keeping their
+// relative paths under quarantine folder
+func QuarantineOrphans(root, quarantine string, orphans []string) ([]string, error) {
+
+	moved := []string{}
+
+	for _, orphan := range orphans {
+		destination := filepath.Join(quarantine, orphan)
+		if err := os.MkdirAll(filepath.Dir(destination), fs.ModeDir|0755); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(filepath.Join(root, orphan), destination); err != nil {
+			return moved, err
+		}
+		moved = append(moved, orphan)
+	}
+
+	return moved, nil
+}
@@ -2,21 +2,31 @@ package repository
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
 )
 
-func (r Repository) NewAudioRecording(rec *model.AudioRecording) error {
-	if err := r.db.Create(rec).Error; err != nil {
-		return err
-	}
-	return nil
+func (r Repository) NewAudioRecording(rec *model.AudioRecording, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		rec.Version = 1
+		if err := tx.Create(rec).Error; err != nil {
+			return err
+		}
+		version := versionFromRecording(*rec)
+		if userID != 0 {
+			version.UserID = &userID
+		}
+		return tx.Create(&version).Error
+	})
 }
 
-func (r Repository) AudioRecordings(sp model.AudioRecordingsSearchParams, data interface{}, count *int64) error {
-	tx := r.db.Preload("Category").Model(&model.AudioRecording{})
+// AudioRecordings returns a page of recordings matching search params, and
+// cursor of the next page when there are more recordings
+func (r Repository) AudioRecordings(sp model.AudioRecordingsSearchParams, data *[]model.AudioRecording, count *int64) (string, error) {
+	tx := r.db.Preload("Category").Preload("Client").Model(&model.AudioRecording{})
 
 	if sp.Category != nil {
 		tx = tx.Where(&model.AudioRecording{CategoryID: *sp.Category})
@@ -25,7 +35,7 @@ func (r Repository) AudioRecordings(sp model.AudioRecordingsSearchParams, data i
 	if sp.FromDate != nil {
 		fdt, err := time.Parse("2006-01-02", *sp.FromDate)
 		if err != nil {
-			return err
+			return "", err
 		}
 		tx = tx.Where("Date >= ?", fdt)
 	}
@@ -33,7 +43,7 @@ func (r Repository) AudioRecordings(sp model.AudioRecordingsSearchParams, data i
 	if sp.ToDate != nil {
 		fdt, err := time.Parse("2006-01-02", *sp.ToDate)
 		if err != nil {
-			return err
+			return "", err
 		}
 		tx = tx.Where("Date <= ?", fdt)
 	}
@@ -46,44 +56,86 @@ func (r Repository) AudioRecordings(sp model.AudioRecordingsSearchParams, data i
 		tx = tx.Where("Name like ?", fmt.Sprintf("%%%s%%", *sp.Name))
 	}
 
-	if sp.Sort != nil {
-		var sortClause string
-		desc := strings.HasPrefix(*sp.Sort, "-")
-		if desc {
-			sortClause = fmt.Sprintf("%s desc", (*sp.Sort)[1:])
+	if sp.Client != nil {
+		tx = tx.Where("Client_ID = ?", *sp.Client)
+	}
+
+	if sp.ValidOn != nil {
+		vdt, err := time.Parse("2006-01-02", *sp.ValidOn)
+		if err != nil {
+			return "", err
+		}
+		tx = tx.Scopes(validOn(vdt))
+	}
+
+	if sp.Expired != nil {
+		today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+		if *sp.Expired {
+			tx = tx.Where("Valid_To < ?", today)
 		} else {
-			sortClause = *sp.Sort
+			tx = tx.Where("Valid_To is null or Valid_To >= ?", today)
 		}
-		tx = tx.Order(sortClause)
 	}
 
-	tx = tx.Count(count)
+	spec := ""
+	if sp.Sort != nil {
+		spec = *sp.Sort
+	}
+	order, err := parseAudioSort(spec)
+	if err != nil {
+		return "", err
+	}
+
+	limit := DefaultPageSize
+	if sp.Count != nil {
+		limit = *sp.Count
+	}
+	if limit < 1 || limit > MaxPageSize {
+		return "", fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidPage, MaxPageSize)
+	}
+
+	if err := tx.Count(count).Error; err != nil {
+		return "", err
+	}
+
+	tx = order.apply(tx)
 
-	if sp.Skip != nil {
+	switch {
+	case sp.Cursor != nil && sp.Skip != nil:
+		return "", fmt.Errorf("%w: skip cannot be used with cursor", ErrInvalidPage)
+	case sp.Cursor != nil:
+		if tx, err = order.after(tx, *sp.Cursor); err != nil {
+			return "", err
+		}
+	case sp.Skip != nil:
+		if *sp.Skip < 0 {
+			return "", fmt.Errorf("%w: skip must not be negative", ErrInvalidPage)
+		}
 		tx = tx.Offset(*sp.Skip)
-	} else {
-		tx = tx.Offset(0)
 	}
-	if sp.Count != nil {
-		tx = tx.Limit(*sp.Count)
-	} else {
-		tx = tx.Limit(20)
+
+	// one more recording tells if there is next page
+	if err := tx.Limit(limit + 1).Find(data).Error; err != nil {
+		return "", err
 	}
 
-	if err := tx.Find(data).Error; err != nil {
-		return err
+	if len(*data) <= limit {
+		return "", nil
 	}
+	*data = (*data)[:limit]
 
-	return nil
+	return order.cursor((*data)[limit-1])
 }
 
+// DeleteAudioRecording moves recording to trash. Its schedules and emit logs
+// are kept, and it can be restored or purged later.
 func (r Repository) DeleteAudioRecording(id int, data interface{}) error {
 
 	if err := r.db.Model(&model.AudioRecording{}).First(data, id).Error; err != nil {
 		return err
 	}
 
-	if err := r.db.Unscoped().Delete(&model.AudioRecording{}, id).Error; err != nil {
+	if err := r.db.Delete(&model.AudioRecording{}, id).Error; err != nil {
 		return err
 	}
 	return nil
@@ -93,6 +145,12 @@ func (r Repository) AudioRecording(id int, data interface{}) error {
 	return r.db.Model(&model.AudioRecording{}).First(data, id).Error
 }
 
+// AudioRecordingsByIDs returns non trashed recordings with given ids, in no
+// particular order
+func (r Repository) AudioRecordingsByIDs(ids []uint, data interface{}) error {
+	return r.db.Preload("Category").Preload("Client").Where("ID in ?", ids).Find(data).Error
+}
+
 func (r Repository) ActiveAudioRecordingsForCategory(catId int, name string, data interface{}) error {
 
 	tx := r.db.Model(&model.AudioRecording{}).
@@ -125,19 +183,47 @@ func (r Repository) UpdateAudioRecording(id int, updateData *model.AudioRecordin
 		return err
 	}
 
+	// dates not sent keep current window ends, empty ones open them
+	validFrom, validTo := updateData.ValidFrom, updateData.ValidTo
+	if validFrom == nil && fnd.ValidFrom != nil {
+		date := fnd.ValidFrom.Format("2006-01-02")
+		validFrom = &date
+	}
+	if validTo == nil && fnd.ValidTo != nil {
+		date := fnd.ValidTo.Format("2006-01-02")
+		validTo = &date
+	}
+	validity := model.AudioRecording{}
+	if err := validity.SetValidity(validFrom, validTo); err != nil {
+		return err
+	}
+
 	updateDict := map[string]interface{}{
-		"Name":     updateData.Name,
-		"Client":   updateData.Client,
-		"Comment":  updateData.Comment,
-		"Active":   updateData.Active,
-		"Category": cat,
+		"Name":      updateData.Name,
+		"Comment":   updateData.Comment,
+		"Active":    updateData.Active,
+		"Category":  cat,
+		"ValidFrom": validity.ValidFrom,
+		"ValidTo":   validity.ValidTo,
+	}
+
+	// client is changed only when sent, zero unlinks it
+	if updateData.ClientID != nil {
+		if *updateData.ClientID == 0 {
+			updateDict["ClientID"] = nil
+		} else {
+			if err := r.CheckClient(*updateData.ClientID); err != nil {
+				return err
+			}
+			updateDict["ClientID"] = *updateData.ClientID
+		}
 	}
 
 	if err := r.db.Model(&fnd).Updates(updateDict).Error; err != nil {
 		return err
 	}
 
-	if err := r.db.Preload("Category").First(data, id).Error; err != nil {
+	if err := r.db.Preload("Category").Preload("Client").First(data, id).Error; err != nil {
 		return err
 	}
 
@@ -148,8 +234,9 @@ func (r Repository) GetAudioLog(sp model.AudioRecordingLogSearchParams, data int
 
 	tx := r.db.
 		Joins("Schedule").
-		Preload("Schedule.Recording").
+		Preload("Schedule.Recording", unscoped).
 		Preload("Schedule.Recording.Category").
+		Preload("Schedule.Recording.Client").
 		Model(&model.EmitLog{}).
 		Where("Schedule.Recording_ID = ?", sp.Recording)
 
@@ -177,3 +264,39 @@ func (r Repository) GetAudioLog(sp model.AudioRecordingLogSearchParams, data int
 
 	return nil
 }
+
+// AudioRecordingByChecksum finds oldest recording with given content, other
+// than excludeID
+func (r Repository) AudioRecordingByChecksum(checksum string, excludeID uint, data *model.AudioRecording) error {
+	return r.db.Preload("Category").Preload("Client").
+		Where(&model.AudioRecording{Checksum: checksum}).
+		Where("ID <> ?", excludeID).
+		Order("Date").
+		First(data).Error
+}
+
+// DuplicateAudioRecordings returns recordings sharing same content checksum,
+// ordered by checksum and upload date
+func (r Repository) DuplicateAudioRecordings(data interface{}) error {
+
+	duplicated := r.db.Model(&model.AudioRecording{}).
+		Select("Checksum").
+		Where("Checksum <> ?", "").
+		Group("Checksum").
+		Having("count(*) > 1")
+
+	return r.db.Preload("Category").Preload("Client").
+		Where("Checksum in (?)", duplicated).
+		Order("Checksum").
+		Order("Date").
+		Find(data).Error
+}
+
+// validOn limits recordings to those whose validity window includes date
+func validOn(date time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("Valid_From is null or Valid_From <= ?", date).
+			Where("Valid_To is null or Valid_To >= ?", date)
+	}
+}
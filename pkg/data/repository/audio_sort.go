@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 500
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidPage   = errors.New("invalid page")
+)
+
+// audioSortField describes column recordings can be sorted by. Value reads
+// the column from a recording and Decode restores it from a cursor, so keyset
+// comparison uses the same type as the column.
+type audioSortField struct {
+	Column string
+	Value  func(rec model.AudioRecording) interface{}
+	Decode func(raw json.RawMessage) (interface{}, error)
+}
+
+// decodeTo returns decoder unmarshalling cursor value into pointer created by
+// newValue and returning the value it points to
+func decodeTo(newValue func() interface{}) func(raw json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		ptr := newValue()
+		if err := json.Unmarshal(raw, ptr); err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(ptr).Elem().Interface(), nil
+	}
+}
+
+// audioSortFields lists sortable columns. Only non nullable columns are
+// allowed, keyset comparison does not handle nulls.
+var audioSortFields = map[string]audioSortField{
+	"id":       {"id", func(rec model.AudioRecording) interface{} { return rec.ID }, decodeTo(func() interface{} { return new(uint) })},
+	"name":     {"name", func(rec model.AudioRecording) interface{} { return rec.Name }, decodeTo(func() interface{} { return new(string) })},
+	"date":     {"date", func(rec model.AudioRecording) interface{} { return rec.Date }, decodeTo(func() interface{} { return new(time.Time) })},
+	"duration": {"duration", func(rec model.AudioRecording) interface{} { return rec.Duration }, decodeTo(func() interface{} { return new(time.Duration) })},
+	"active":   {"active", func(rec model.AudioRecording) interface{} { return rec.Active }, decodeTo(func() interface{} { return new(bool) })},
+	"size":     {"size", func(rec model.AudioRecording) interface{} { return rec.Size }, decodeTo(func() interface{} { return new(int64) })},
+	"category": {"category_id", func(rec model.AudioRecording) interface{} { return rec.CategoryID }, decodeTo(func() interface{} { return new(int) })},
+}
+
+type audioSortTerm struct {
+	Name  string
+	Field audioSortField
+	Desc  bool
+}
+
+type audioSort []audioSortTerm
+
+// parseAudioSort parses comma separated list of sort fields, each optionally
+// prefixed with "-" for descending order. Id is always appended, so order is
+// total and usable for keyset pagination.
+func parseAudioSort(spec string) (audioSort, error) {
+
+	terms := audioSort{}
+	seen := map[string]bool{}
+
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		desc := strings.HasPrefix(term, "-")
+		name := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(term, "-"), "+"))
+		field, ok := audioSortFields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %s, allowed fields: %s", ErrInvalidSort, name, allowedAudioSortFields())
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: field %s used more than once", ErrInvalidSort, name)
+		}
+		seen[name] = true
+		terms = append(terms, audioSortTerm{Name: name, Field: field, Desc: desc})
+	}
+
+	if !seen["id"] {
+		terms = append(terms, audioSortTerm{Name: "id", Field: audioSortFields["id"]})
+	}
+
+	return terms, nil
+}
+
+func allowedAudioSortFields() string {
+	names := []string{}
+	for name := range audioSortFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (s audioSort) String() string {
+	terms := []string{}
+	for _, term := range s {
+		if term.Desc {
+			terms = append(terms, "-"+term.Name)
+		} else {
+			terms = append(terms, term.Name)
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+func (s audioSort) apply(tx *gorm.DB) *gorm.DB {
+	for _, term := range s {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: term.Field.Column}, Desc: term.Desc})
+	}
+	return tx
+}
+
+type audioCursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// cursor encodes sort values of the last recording on the page
+func (s audioSort) cursor(rec model.AudioRecording) (string, error) {
+	c := audioCursor{Sort: s.String()}
+	for _, term := range s {
+		raw, err := json.Marshal(term.Field.Value(rec))
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// after limits query to recordings following the cursor position, e.g. for
+// "-date,id" it is: date < ? or (date = ? and id > ?)
+func (s audioSort) after(tx *gorm.DB, cursor string) (*gorm.DB, error) {
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := audioCursor{}
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != s.String() || len(c.Values) != len(s) {
+		return nil, fmt.Errorf("%w: cursor was created for another sort order", ErrInvalidCursor)
+	}
+
+	values := []interface{}{}
+	for i, term := range s {
+		v, err := term.Field.Decode(c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, v)
+	}
+
+	alternatives := []string{}
+	args := []interface{}{}
+	for i, term := range s {
+		conditions := []string{}
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, fmt.Sprintf("%s = ?", s[j].Field.Column))
+			args = append(args, values[j])
+		}
+		op := ">"
+		if term.Desc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s ?", term.Field.Column, op))
+		args = append(args, values[i])
+		alternatives = append(alternatives, "("+strings.Join(conditions, " and ")+")")
+	}
+
+	return tx.Where(strings.Join(alternatives, " or "), args...), nil
+}
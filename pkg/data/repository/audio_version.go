@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"time"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+func versionFromRecording(rec model.AudioRecording) model.AudioRecordingVersion {
+	return model.AudioRecordingVersion{
+		RecordingID: int(rec.ID),
+		Version:     rec.Version,
+		Path:        rec.Path,
+		Duration:    rec.Duration,
+		SampleRate:  rec.SampleRate,
+		Channels:    rec.Channels,
+		Bitrate:     rec.Bitrate,
+		Checksum:    rec.Checksum,
+		Size:        rec.Size,
+		UploadedAt:  rec.Date,
+	}
+}
+
+// ensureInitialVersion records current file of recordings created before
+// version history existed
+func ensureInitialVersion(tx *gorm.DB, rec *model.AudioRecording) error {
+	var count int64
+	if err := tx.Model(&model.AudioRecordingVersion{}).Where("Recording_ID = ?", rec.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+	if rec.Version == 0 {
+		rec.Version = 1
+		if err := tx.Model(rec).Update("Version", rec.Version).Error; err != nil {
+			return err
+		}
+	}
+	version := versionFromRecording(*rec)
+	return tx.Create(&version).Error
+}
+
+// ReplaceAudioRecordingFile adds new file version and makes it current.
+// Recording keeps its identity, so schedules and emit logs stay attached.
+func (r Repository) ReplaceAudioRecordingFile(id int, version model.AudioRecordingVersion, data *model.AudioRecording) error {
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		rec := model.AudioRecording{}
+		if err := tx.First(&rec, id).Error; err != nil {
+			return err
+		}
+		if err := ensureInitialVersion(tx, &rec); err != nil {
+			return err
+		}
+
+		var last struct {
+			Version int
+		}
+		if err := tx.Model(&model.AudioRecordingVersion{}).
+			Select("max(Version) as version").
+			Where("Recording_ID = ?", rec.ID).
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		version.RecordingID = int(rec.ID)
+		version.Version = last.Version + 1
+		version.UploadedAt = time.Now()
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+
+		return setCurrentVersion(tx, &rec, version)
+	}); err != nil {
+		return err
+	}
+
+	return r.db.Preload("Category").Preload("Client").First(data, id).Error
+}
+
+func (r Repository) AudioRecordingVersions(id int, data interface{}) error {
+
+	rec := model.AudioRecording{}
+	if err := r.db.First(&rec, id).Error; err != nil {
+		return err
+	}
+
+	return r.db.
+		Preload("User").
+		Preload("Recording").
+		Where("Recording_ID = ?", id).
+		Order("Version desc").
+		Find(data).Error
+}
+
+// RestoreAudioRecordingVersion makes earlier file version current again
+func (r Repository) RestoreAudioRecordingVersion(id int, version int, data *model.AudioRecording) error {
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		rec := model.AudioRecording{}
+		if err := tx.First(&rec, id).Error; err != nil {
+			return err
+		}
+		ver := model.AudioRecordingVersion{}
+		if err := tx.Where("Recording_ID = ? and Version = ?", id, version).First(&ver).Error; err != nil {
+			return err
+		}
+		return setCurrentVersion(tx, &rec, ver)
+	}); err != nil {
+		return err
+	}
+
+	return r.db.Preload("Category").Preload("Client").First(data, id).Error
+}
+
+// setCurrentVersion makes version current one of recording, schedules of the
+// recording take its duration as well
+func setCurrentVersion(tx *gorm.DB, rec *model.AudioRecording, version model.AudioRecordingVersion) error {
+	if err := tx.Model(&model.Schedule{}).
+		Where("Recording_ID = ?", rec.ID).
+		Update("Duration", version.Duration).Error; err != nil {
+		return err
+	}
+	return tx.Model(rec).Updates(map[string]interface{}{
+		"Path":       version.Path,
+		"Duration":   version.Duration,
+		"SampleRate": version.SampleRate,
+		"Channels":   version.Channels,
+		"Bitrate":    version.Bitrate,
+		"Checksum":   version.Checksum,
+		"Size":       version.Size,
+		"Version":    version.Version,
+	}).Error
+}
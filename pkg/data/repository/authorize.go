@@ -1,13 +1,97 @@
 package repository
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
 	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
 )
 
-func (r Repository) Authorize(username, password string, data interface{}) error {
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("session is expired or revoked")
+)
+
+func (r Repository) Authorize(username, password string, data *model.User) error {
 
 	if err := r.db.Model(&model.User{}).Where(&model.User{Username: username}).First(data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	if !data.Active || !data.CheckPassword(password) {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (r Repository) NewSession(userID uint, ttl time.Duration) (*model.Session, error) {
+
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := model.Session{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := r.db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Preload("User").First(&session, session.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r Repository) Session(token string, data *model.Session) error {
+
+	if err := r.db.Preload("User").Where(&model.Session{Token: token}).First(data).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidSession
+		}
 		return err
 	}
+
+	if !data.Valid(time.Now()) || !data.User.Active {
+		return ErrInvalidSession
+	}
+
 	return nil
 }
+
+func (r Repository) RevokeSession(token string) error {
+
+	tx := r.db.Model(&model.Session{}).Where("Token = ?", token).Update("Revoked", true)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrInvalidSession
+	}
+	return nil
+}
+
+func (r Repository) PurgeExpiredSessions() error {
+	return r.db.Unscoped().Where("Expires_At < ? or Revoked = ?", time.Now(), true).Delete(&model.Session{}).Error
+}
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
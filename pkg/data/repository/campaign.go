@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCampaignExists           = errors.New("campaign with same name already exists")
+	ErrCampaignInUse            = errors.New("campaign has schedules")
+	ErrInvalidCampaignDates     = errors.New("campaign ends before it starts")
+	ErrUnknownRecording         = errors.New("recording not found")
+	ErrSchedulesOutsideCampaign = errors.New("campaign has schedules outside new date range or for removed recordings")
+	ErrRecordingNotInCampaign   = errors.New("recording is not part of the campaign")
+	ErrOutsideCampaign          = errors.New("schedule date is outside campaign date range")
+)
+
+func preloadCampaign(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Client").
+		Preload("Recordings").
+		Preload("Recordings.Category").
+		Preload("Recordings.Client")
+}
+
+func (r Repository) Campaigns(sp model.CampaignSearchParams, data interface{}) error {
+
+	tx := preloadCampaign(r.db).Model(&model.Campaign{}).Order("Start_Date desc")
+
+	if sp.Client != nil {
+		tx = tx.Where("Client_ID = ?", *sp.Client)
+	}
+
+	if sp.ActiveOn != nil {
+		date, err := time.Parse("2006-01-02", *sp.ActiveOn)
+		if err != nil {
+			return err
+		}
+		tx = tx.Where("Start_Date <= ? and End_Date >= ?", date, date)
+	}
+
+	return tx.Find(data).Error
+}
+
+func (r Repository) Campaign(id int, data interface{}) error {
+	return preloadCampaign(r.db).Model(&model.Campaign{}).First(data, id).Error
+}
+
+func (r Repository) NewCampaign(data model.NewCampaignDTO) (*model.Campaign, error) {
+
+	campaign := model.Campaign{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureUniqueCampaignName(tx, data.Name, 0); err != nil {
+			return err
+		}
+		if err := setCampaignData(tx, &campaign, data); err != nil {
+			return err
+		}
+		return tx.Omit("Recordings.*").Create(&campaign).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Campaign(int(campaign.ID), &campaign); err != nil {
+		return nil, err
+	}
+
+	return &campaign, nil
+}
+
+func (r Repository) SetCampaign(id int, data model.NewCampaignDTO) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		campaign := model.Campaign{}
+		if err := tx.First(&campaign, id).Error; err != nil {
+			return err
+		}
+
+		if err := ensureUniqueCampaignName(tx, data.Name, campaign.ID); err != nil {
+			return err
+		}
+
+		if err := setCampaignData(tx, &campaign, data); err != nil {
+			return err
+		}
+
+		// already scheduled spots must still fit the campaign
+		recordingIDs := []uint{0}
+		for _, rec := range campaign.Recordings {
+			recordingIDs = append(recordingIDs, rec.ID)
+		}
+		var count int64
+		if err := tx.Model(&model.Schedule{}).
+			Where("Campaign_ID = ?", campaign.ID).
+			Where("Date < ? or Date > ? or Recording_ID not in ?", campaign.StartDate, campaign.EndDate, recordingIDs).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			return ErrSchedulesOutsideCampaign
+		}
+
+		if err := tx.Model(&campaign).Omit("Recordings.*").Association("Recordings").Replace(campaign.Recordings); err != nil {
+			return err
+		}
+		return tx.Omit("Recordings").Select("*").Updates(&campaign).Error
+	})
+}
+
+func (r Repository) DeleteCampaign(id int) error {
+
+	campaign := model.Campaign{}
+	if err := r.db.First(&campaign, id).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := r.db.Model(&model.Schedule{}).Where("Campaign_ID = ?", campaign.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrCampaignInUse
+	}
+
+	return r.db.Select("Recordings").Delete(&campaign).Error
+}
+
+// CampaignStatus compares spots sold by campaign with spots scheduled and
+// already aired
+func (r Repository) CampaignStatus(id int) (*model.CampaignStatusDTO, error) {
+
+	campaign := model.Campaign{}
+	if err := r.Campaign(id, &campaign); err != nil {
+		return nil, err
+	}
+
+	type totals struct {
+		Shift1, Shift2, Shift3, Shift4     int
+		Played1, Played2, Played3, Played4 int
+	}
+	t := totals{}
+	if err := r.db.Model(&model.Schedule{}).
+		Where("Campaign_ID = ?", campaign.ID).
+		Select("coalesce(sum(shift1), 0) as shift1, coalesce(sum(shift2), 0) as shift2, " +
+			"coalesce(sum(shift3), 0) as shift3, coalesce(sum(shift4), 0) as shift4, " +
+			"coalesce(sum(shift1_played), 0) as played1, coalesce(sum(shift2_played), 0) as played2, " +
+			"coalesce(sum(shift3_played), 0) as played3, coalesce(sum(shift4_played), 0) as played4").
+		Scan(&t).Error; err != nil {
+		return nil, err
+	}
+
+	status := model.CampaignStatusDTO{
+		Campaign: campaign.Map(),
+		Sold:     campaign.SoldSpots(),
+		Scheduled: model.SpotsDTO{
+			Shift1: t.Shift1,
+			Shift2: t.Shift2,
+			Shift3: t.Shift3,
+			Shift4: t.Shift4,
+			Total:  t.Shift1 + t.Shift2 + t.Shift3 + t.Shift4,
+		},
+		Aired: model.SpotsDTO{
+			Shift1: t.Played1,
+			Shift2: t.Played2,
+			Shift3: t.Played3,
+			Shift4: t.Played4,
+			Total:  t.Played1 + t.Played2 + t.Played3 + t.Played4,
+		},
+	}
+	status.Unscheduled = status.Sold.Total - status.Scheduled.Total
+
+	return &status, nil
+}
+
+// checkScheduleCampaign makes sure recording is scheduled within one of its
+// campaigns
+func checkScheduleCampaign(tx *gorm.DB, campaignID int, recordingID int, date time.Time) (*model.Campaign, error) {
+
+	campaign := model.Campaign{}
+	if err := tx.Preload("Recordings", "Audio_Recordings.ID = ?", recordingID).First(&campaign, campaignID).Error; err != nil {
+		return nil, err
+	}
+	if len(campaign.Recordings) == 0 {
+		return nil, ErrRecordingNotInCampaign
+	}
+	if !campaign.Includes(date) {
+		return nil, ErrOutsideCampaign
+	}
+
+	return &campaign, nil
+}
+
+func ensureUniqueCampaignName(tx *gorm.DB, name string, excludeID uint) error {
+	var count int64
+	if err := tx.Model(&model.Campaign{}).
+		Where("Name = ? and ID <> ?", name, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrCampaignExists
+	}
+	return nil
+}
+
+func setCampaignData(tx *gorm.DB, campaign *model.Campaign, data model.NewCampaignDTO) error {
+
+	startDate, err := time.Parse("2006-01-02", data.StartDate)
+	if err != nil {
+		return err
+	}
+	endDate, err := time.Parse("2006-01-02", data.EndDate)
+	if err != nil {
+		return err
+	}
+	if endDate.Before(startDate) {
+		return ErrInvalidCampaignDates
+	}
+
+	var count int64
+	if err := tx.Model(&model.Client{}).Where("ID = ?", data.Client).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnknownClient
+	}
+
+	recordings := []model.AudioRecording{}
+	if len(data.Recordings) > 0 {
+		if err := tx.Find(&recordings, data.Recordings).Error; err != nil {
+			return err
+		}
+	}
+	if len(recordings) != len(uniqueIDs(data.Recordings)) {
+		return ErrUnknownRecording
+	}
+
+	clientID := data.Client
+	campaign.Name = data.Name
+	campaign.ClientID = &clientID
+	campaign.StartDate = startDate
+	campaign.EndDate = endDate
+	campaign.Spots1 = data.Spots1
+	campaign.Spots2 = data.Spots2
+	campaign.Spots3 = data.Spots3
+	campaign.Spots4 = data.Spots4
+	campaign.SpotsTotal = data.SpotsTotal
+	campaign.Price = data.Price
+	campaign.Notes = data.Notes
+	campaign.Recordings = recordings
+
+	return nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	res := map[uint]bool{}
+	for _, id := range ids {
+		res[id] = true
+	}
+	return res
+}
+
+// migrateCampaigns creates a campaign for each recording scheduled before
+// campaigns were introduced, covering all of its existing schedules
+func migrateCampaigns(db *gorm.DB) error {
+
+	schedules := []model.Schedule{}
+	if err := db.Unscoped().Model(&model.Schedule{}).
+		Where("Campaign_ID is null").
+		Order("Recording_ID").Order("Date").
+		Find(&schedules).Error; err != nil {
+		return err
+	}
+
+	campaigns := map[int]*model.Campaign{}
+	order := []int{}
+	for _, schedule := range schedules {
+		campaign, ok := campaigns[schedule.RecordingID]
+		if !ok {
+			campaign = &model.Campaign{StartDate: schedule.Date}
+			campaigns[schedule.RecordingID] = campaign
+			order = append(order, schedule.RecordingID)
+		}
+		campaign.EndDate = schedule.Date
+		campaign.SpotsTotal += schedule.Shift1 + schedule.Shift2 + schedule.Shift3 + schedule.Shift4
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, recordingID := range order {
+			rec := model.AudioRecording{}
+			if err := tx.Unscoped().First(&rec, recordingID).Error; err != nil {
+				return err
+			}
+			campaign := campaigns[recordingID]
+			campaign.Name = fmt.Sprintf("%s (#%d)", rec.Name, rec.ID)
+			campaign.ClientID = rec.ClientID
+			campaign.Notes = "created from existing schedules"
+			campaign.Recordings = []model.AudioRecording{rec}
+			if err := tx.Omit("Recordings.*").Create(campaign).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&model.Schedule{}).
+				Where("Campaign_ID is null and Recording_ID = ?", recordingID).
+				Update("Campaign_ID", campaign.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClientExists    = errors.New("client with same name already exists")
+	ErrClientInUse     = errors.New("client has recordings or campaigns, merge it into another client instead")
+	ErrUnknownClient   = errors.New("client not found")
+	ErrClientMergeSelf = errors.New("client cannot be merged into itself")
+)
+
+func (r Repository) Clients(name string, data interface{}) error {
+	tx := r.db.Model(&model.Client{}).Order("Name")
+	if name != "" {
+		tx = tx.Where("Name like ?", "%"+name+"%")
+	}
+	return tx.Find(data).Error
+}
+
+func (r Repository) Client(id int, data interface{}) error {
+	return r.db.Model(&model.Client{}).First(data, id).Error
+}
+
+// CheckClient makes sure client recording is linked to exists
+func (r Repository) CheckClient(id uint) error {
+	var count int64
+	if err := r.db.Model(&model.Client{}).Where("ID = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnknownClient
+	}
+	return nil
+}
+
+func (r Repository) NewClient(data model.ClientDTO) (*model.Client, error) {
+
+	if err := r.ensureUniqueClientName(data.Name, 0); err != nil {
+		return nil, err
+	}
+
+	client := model.Client{}
+	setClientData(&client, data)
+	if err := r.db.Create(&client).Error; err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (r Repository) SetClient(id int, data model.ClientDTO) error {
+
+	client := model.Client{}
+	if err := r.db.First(&client, id).Error; err != nil {
+		return err
+	}
+
+	if err := r.ensureUniqueClientName(data.Name, client.ID); err != nil {
+		return err
+	}
+
+	setClientData(&client, data)
+	return r.db.Select("*").Updates(&client).Error
+}
+
+// DeleteClient removes client without recordings, including trashed ones, and
+// without campaigns
+func (r Repository) DeleteClient(id int) error {
+
+	client := model.Client{}
+	if err := r.db.First(&client, id).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := r.db.Unscoped().Model(&model.AudioRecording{}).Where("Client_ID = ?", client.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrClientInUse
+	}
+	if err := r.db.Unscoped().Model(&model.Campaign{}).Where("Client_ID = ?", client.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrClientInUse
+	}
+
+	return r.db.Delete(&client).Error
+}
+
+// MergeClients moves recordings and campaigns of source clients to target
+// client and removes source clients
+func (r Repository) MergeClients(target int, sources []uint) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		client := model.Client{}
+		if err := tx.First(&client, target).Error; err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			if source == client.ID {
+				return ErrClientMergeSelf
+			}
+			sourceClient := model.Client{}
+			if err := tx.First(&sourceClient, source).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&model.AudioRecording{}).
+				Where("Client_ID = ?", sourceClient.ID).
+				Update("Client_ID", client.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&model.Campaign{}).
+				Where("Client_ID = ?", sourceClient.ID).
+				Update("Client_ID", client.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&sourceClient).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ClientDuplicates returns groups of clients whose names look the same once
+// case, punctuation and legal form are ignored
+func (r Repository) ClientDuplicates() ([]model.ClientDuplicatesDTO, error) {
+
+	clients := []model.Client{}
+	if err := r.Clients("", &clients); err != nil {
+		return nil, err
+	}
+
+	groups := map[string][]model.ClientDTO{}
+	for _, client := range clients {
+		key := ClientNameKey(client.Name)
+		groups[key] = append(groups[key], client.Map())
+	}
+
+	res := []model.ClientDuplicatesDTO{}
+	for key, group := range groups {
+		if len(group) > 1 {
+			res = append(res, model.ClientDuplicatesDTO{Key: key, Clients: group})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+
+	return res, nil
+}
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	// legal forms commonly found in advertiser names
+	legalForms = map[string]bool{
+		"doo": true, "ad": true, "szr": true, "str": true, "sztr": true,
+		"pr": true, "llc": true, "ltd": true, "inc": true, "gmbh": true,
+	}
+)
+
+// ClientNameKey normalizes client name for duplicate detection
+func ClientNameKey(name string) string {
+	name = strings.ToLower(name)
+	// "d.o.o." and "d o o" should both end up as "doo"
+	name = strings.NewReplacer(".", "").Replace(name)
+	words := []string{}
+	for _, word := range strings.Fields(nonAlphanumeric.ReplaceAllString(name, " ")) {
+		if !legalForms[word] {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (r Repository) ensureUniqueClientName(name string, excludeID uint) error {
+	var count int64
+	if err := r.db.Model(&model.Client{}).
+		Where("Name = ? and ID <> ?", strings.TrimSpace(name), excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 0 {
+		return ErrClientExists
+	}
+	return nil
+}
+
+func setClientData(client *model.Client, data model.ClientDTO) {
+	client.Name = strings.TrimSpace(data.Name)
+	client.ContactPerson = data.ContactPerson
+	client.Email = data.Email
+	client.Phone = data.Phone
+	client.Address = data.Address
+	client.TaxID = data.TaxID
+	client.Notes = data.Notes
+}
+
+// migrateClients turns free text client names, stored on recordings before
+// clients were introduced, into client rows. Names differing only in
+// surrounding whitespace share a client, other near duplicates are left for
+// MergeClients. Migrated names are cleared, so later startups do not relink
+// recordings unlinked since or bring back merged clients.
+func migrateClients(db *gorm.DB) error {
+
+	if !db.Migrator().HasColumn(&model.AudioRecording{}, "client") {
+		return nil
+	}
+
+	type legacyClient struct {
+		ID     uint
+		Client string
+	}
+	legacy := []legacyClient{}
+	if err := db.Unscoped().Model(&model.AudioRecording{}).
+		Select("ID, Client").
+		Where("Client_ID is null and Client is not null and trim(Client) <> ''").
+		Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		clients := map[string]uint{}
+		for _, rec := range legacy {
+			name := strings.TrimSpace(rec.Client)
+			id, ok := clients[name]
+			if !ok {
+				client := model.Client{}
+				if err := tx.Where(&model.Client{Name: name}).FirstOrCreate(&client).Error; err != nil {
+					return err
+				}
+				id = client.ID
+				clients[name] = id
+			}
+			if err := tx.Unscoped().Model(&model.AudioRecording{}).Where("ID = ?", rec.ID).Update("Client_ID", id).Error; err != nil {
+				return err
+			}
+		}
+		// column is no longer on the model, so update goes to the table
+		return tx.Table("audio_recordings").
+			Where("Client is not null").
+			Update("Client", nil).Error
+	})
+}
+
+// ClientAudioRecordings returns recordings linked to client, excluding trashed
+func (r Repository) ClientAudioRecordings(clientID uint, data interface{}) error {
+	return r.db.Preload("Category").Preload("Client").Where("Client_ID = ?", clientID).Find(data).Error
+}
@@ -83,9 +83,12 @@ func (r Repository) DispositionForShiftAndData(shift int, date time.Time) ([]mod
 	// find schedule data
 	schedules := []model.Schedule{}
 
+	// trashed and expired recordings are not played
 	tx := r.db.Preload("Recording").
 		Preload("Recording.Category").
-		Where("Date = ? and Has_Disposition = true", date)
+		Preload("Recording.Client").
+		Where("Date = ? and Has_Disposition = true", date).
+		Where("Recording_ID in (?)", r.db.Model(&model.AudioRecording{}).Scopes(validOn(date)).Select("ID"))
 	if err := tx.Find(&schedules).Error; err != nil {
 		return nil, err
 	}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+// ReferencedAudioPaths returns paths of all files referenced by recordings,
+// including trashed recordings and earlier file versions
+func (r Repository) ReferencedAudioPaths() ([]string, error) {
+
+	recordingPaths := []string{}
+	if err := r.db.Unscoped().Model(&model.AudioRecording{}).Distinct().Pluck("Path", &recordingPaths).Error; err != nil {
+		return nil, err
+	}
+
+	versionPaths := []string{}
+	if err := r.db.Model(&model.AudioRecordingVersion{}).Distinct().Pluck("Path", &versionPaths).Error; err != nil {
+		return nil, err
+	}
+
+	return append(recordingPaths, versionPaths...), nil
+}
+
+func (r Repository) AllAudioRecordings(data interface{}) error {
+	return r.db.Preload("Category").Preload("Client").Order("Path").Find(data).Error
+}
+
+func (r Repository) CategoryByPath(path string, data *model.Category) error {
+	res := r.db.Model(&model.Category{}).Where(&model.Category{Path: path}).Limit(1).Find(data)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AudioRecordingNameExists checks names of all recordings, including trashed
+// ones. Unique index covers active recordings only, but a trashed recording
+// sharing the name could not be restored later.
+func (r Repository) AudioRecordingNameExists(name string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.AudioRecording{}).Where("Name = ?", name).Count(&count).Error
+	return count != 0, err
+}
+
+func (r Repository) SetAudioRecordingFileInfo(id uint, checksum string, size int64) error {
+	return r.db.Model(&model.AudioRecording{}).Where("ID = ?", id).Updates(map[string]interface{}{
+		"Checksum": checksum,
+		"Size":     size,
+	}).Error
+}
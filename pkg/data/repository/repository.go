@@ -24,6 +24,15 @@ type RepositoryConfig struct {
 	Logger  gormlogger.Interface
 }
 
+// DefaultUsers were seeded on an empty database by earlier versions, each
+// with its username as password.
+var DefaultUsers = []model.User{
+	{Username: "maki", Level: model.Admin},
+	{Username: "taki", Level: model.Regular},
+	{Username: "laki", Level: model.Regular},
+	{Username: "caki", Level: model.Regular},
+}
+
 func NewRepository(cfg RepositoryConfig) (*Repository, error) {
 
 	var err error
@@ -59,16 +68,20 @@ func NewRepository(cfg RepositoryConfig) (*Repository, error) {
 	models := []interface{}{
 		&model.Shift{},
 		&model.Category{},
+		&model.Client{},
 		&model.AudioRecording{},
+		&model.AudioRecordingVersion{},
+		&model.Campaign{},
 		//&model.Disposition{},
 		//&model.DispositionPlayed{},
 		&model.User{},
+		&model.Session{},
 		&model.Schedule{},
 		&model.Equalizer{},
 		&model.EmitLog{},
 	}
 
-	if err = db.AutoMigrate(models...); err != nil {
+	if err = migrate(db, models); err != nil {
 		return nil, err
 	}
 
@@ -80,16 +93,37 @@ func NewRepository(cfg RepositoryConfig) (*Repository, error) {
 		return nil, err
 	}
 
-	if err = initUsers(db); err != nil {
-		return nil, err
-	}
-
 	repo.db = db
 
 	return repo, nil
 
 }
 
+// migrate runs schema migration on a single connection. Sqlite adds
+// constraints by rebuilding the table, and dropping the old one would cascade
+// deletes to dependent rows, so foreign keys are off meanwhile.
+func migrate(db *gorm.DB, models []interface{}) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		if conn.Dialector.Name() == "sqlite" {
+			if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+				return err
+			}
+			defer conn.Exec("PRAGMA foreign_keys = ON")
+		}
+
+		if err := conn.AutoMigrate(models...); err != nil {
+			return err
+		}
+
+		if err := migrateClients(conn); err != nil {
+			return err
+		}
+
+		return migrateCampaigns(conn)
+	})
+}
+
 func initCategories(db *gorm.DB) error {
 	var count int64
 	if err := db.Model(&model.Category{}).Count(&count).Error; err != nil {
@@ -164,32 +198,10 @@ func initShifts(db *gorm.DB) error {
 	return nil
 }
 
-func initUsers(db *gorm.DB) error {
-	var count int64
-	if err := db.Model(&model.User{}).Count(&count).Error; err != nil {
-		return err
-	}
-	if count != 0 {
-		return nil
-	}
-
-	predefinedUsers := []model.User{
-		{Username: "maki", Password: "maki", Level: model.Admin},
-		{Username: "taki", Password: "taki", Level: model.Regular},
-		{Username: "laki", Password: "laki", Level: model.Regular},
-		{Username: "caki", Password: "caki", Level: model.Regular},
-	}
-
-	for _, u := range predefinedUsers {
-		if err := u.SetPassword(u.Username); err != nil {
-			return err
-		}
-		if err := db.Create(&u).Error; err != nil {
-			return err
-		}
-	}
-
-	return nil
+// unscoped is used to preload soft deleted (trashed) associations, so
+// history referencing them stays complete
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
 }
 
 func createDialector(dbUrl *url.URL) (gorm.Dialector, error) {
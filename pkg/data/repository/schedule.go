@@ -10,15 +10,24 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrOutsideValidity      = errors.New("schedule date is outside recording validity window")
+	ErrScheduledForCampaign = errors.New("recording is already scheduled for that date in another campaign")
+)
+
 func (r Repository) Schedules(sp model.ScheduleSearchParams, data interface{}) error {
 	var err error
 
 	tx := r.db.
-		Preload("Recording").
-		Preload("Recording.Category")
+		Preload("Recording", unscoped).
+		Preload("Recording.Category").
+		Preload("Recording.Client")
 	if sp.Recording != nil {
 		tx = tx.Where(&model.Schedule{RecordingID: *sp.Recording})
 	}
+	if sp.Campaign != nil {
+		tx = tx.Where("Campaign_ID = ?", *sp.Campaign)
+	}
 	if sp.FromDate != nil {
 		fdt, err := time.Parse("2006-01-02", *sp.FromDate)
 		if err != nil {
@@ -43,8 +52,9 @@ func (r Repository) Schedules(sp model.ScheduleSearchParams, data interface{}) e
 
 func (r Repository) Schedule(id int, data interface{}) error {
 	return r.db.
-		Preload("Recording").
+		Preload("Recording", unscoped).
 		Preload("Recording.Category").
+		Preload("Recording.Client").
 		First(data, id).Error
 }
 
@@ -74,6 +84,16 @@ func (r Repository) SetSchedule(id int, data model.NewScheduleDTO) error {
 		return err
 	}
 
+	if !sch.Recording.ValidOn(scheduleDate) {
+		return ErrOutsideValidity
+	}
+
+	campaign, err := checkScheduleCampaign(r.db, data.Campaign, sch.RecordingID, scheduleDate)
+	if err != nil {
+		return err
+	}
+	sch.CampaignID = &campaign.ID
+
 	sch.RecordingID = data.Recording
 	sch.Date = scheduleDate
 	sch.Duration = sch.Recording.Duration
@@ -103,11 +123,21 @@ func (r Repository) NewSchedule(dto model.NewScheduleDTO) (*model.Schedule, erro
 		return nil, err
 	}
 
+	if !rec.ValidOn(dd) {
+		return nil, ErrOutsideValidity
+	}
+
+	campaign, err := checkScheduleCampaign(r.db, dto.Campaign, int(rec.ID), dd)
+	if err != nil {
+		return nil, err
+	}
+
 	// do we have a schedule for same date and same audio recording?
 	existingSchedule := model.Schedule{}
 	if err = r.db.
 		Preload("Recording").
 		Preload("Recording.Category").
+		Preload("Recording.Client").
 		Model(&model.Schedule{}).
 		Where("Date = ? and Recording_id = ?", dd, rec.ID).
 		First(&existingSchedule).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -116,6 +146,9 @@ func (r Repository) NewSchedule(dto model.NewScheduleDTO) (*model.Schedule, erro
 
 	if err == nil {
 		// no error, meaning yes, schedule exists
+		if existingSchedule.CampaignID == nil || *existingSchedule.CampaignID != campaign.ID {
+			return nil, ErrScheduledForCampaign
+		}
 		return &existingSchedule, nil
 	}
 
@@ -128,13 +161,14 @@ func (r Repository) NewSchedule(dto model.NewScheduleDTO) (*model.Schedule, erro
 		Date:           dd,
 		TotalPlayCount: 0,
 		RecordingID:    dto.Recording,
+		CampaignID:     &campaign.ID,
 	}
 
 	if err := r.db.Create(&sch).Error; err != nil {
 		return nil, err
 	}
 
-	if err := r.db.Preload("Recording").Preload("Recording.Category").Find(&sch).Error; err != nil {
+	if err := r.db.Preload("Recording").Preload("Recording.Category").Preload("Recording.Client").Find(&sch).Error; err != nil {
 		return nil, err
 	}
 
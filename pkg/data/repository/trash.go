@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotInTrash   = errors.New("audio recording is not in trash")
+	ErrNameConflict = errors.New("another audio recording with same name exists")
+)
+
+func (r Repository) TrashedAudioRecordings(data interface{}) error {
+	return r.db.Unscoped().
+		Preload("Category").
+		Preload("Client").
+		Where("Deleted_At is not null").
+		Order("Deleted_At desc").
+		Find(data).Error
+}
+
+func (r Repository) RestoreAudioRecording(id int, data *model.AudioRecording) error {
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		rec := model.AudioRecording{}
+		if err := tx.Unscoped().First(&rec, id).Error; err != nil {
+			return err
+		}
+		if !rec.DeletedAt.Valid {
+			return ErrNotInTrash
+		}
+
+		var count int64
+		if err := tx.Model(&model.AudioRecording{}).Where("Name = ?", rec.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			return ErrNameConflict
+		}
+
+		return tx.Unscoped().Model(&rec).Update("Deleted_At", nil).Error
+	}); err != nil {
+		return err
+	}
+
+	return r.db.Preload("Category").Preload("Client").First(data, id).Error
+}
+
+// PurgeAudioRecording permanently removes trashed recording with its version
+// history, schedules and emit logs. Returned file paths, relative to media
+// root, are no longer referenced by any recording and can be removed.
+func (r Repository) PurgeAudioRecording(id int) ([]string, error) {
+
+	unreferenced := []string{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		rec := model.AudioRecording{}
+		if err := tx.Unscoped().First(&rec, id).Error; err != nil {
+			return err
+		}
+		if !rec.DeletedAt.Valid {
+			return ErrNotInTrash
+		}
+
+		paths := []string{}
+		if err := tx.Model(&model.AudioRecordingVersion{}).
+			Where("Recording_ID = ?", id).
+			Distinct().
+			Pluck("Path", &paths).Error; err != nil {
+			return err
+		}
+		paths = append(paths, rec.Path)
+
+		if err := tx.Unscoped().Delete(&rec).Error; err != nil {
+			return err
+		}
+
+		seen := map[string]bool{}
+		for _, path := range paths {
+			if path == "" || seen[path] {
+				continue
+			}
+			seen[path] = true
+			referenced, err := isPathReferenced(tx, path)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				unreferenced = append(unreferenced, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return unreferenced, nil
+}
+
+func isPathReferenced(tx *gorm.DB, path string) (bool, error) {
+	var count int64
+	if err := tx.Unscoped().Model(&model.AudioRecording{}).Where("Path = ?", path).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count != 0 {
+		return true, nil
+	}
+	if err := tx.Model(&model.AudioRecordingVersion{}).Where("Path = ?", path).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
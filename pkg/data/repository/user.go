@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"ozz-ms/pkg/data/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserExists = errors.New("user with same username already exists")
+	ErrLastAdmin  = errors.New("operation would leave no active admin user")
+)
+
+func (r Repository) Users(data interface{}) error {
+	return r.db.Model(&model.User{}).Order("Username").Find(data).Error
+}
+
+func (r Repository) User(id int, data *model.User) error {
+	return r.db.Model(&model.User{}).First(data, id).Error
+}
+
+func (r Repository) UserByName(username string, data *model.User) error {
+	return r.db.Model(&model.User{}).Where(&model.User{Username: username}).First(data).Error
+}
+
+func (r Repository) NewUser(username, password string, level model.UserLevel) (*model.User, error) {
+
+	var count int64
+	if err := r.db.Model(&model.User{}).Where(&model.User{Username: username}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != 0 {
+		return nil, ErrUserExists
+	}
+
+	user := model.User{
+		Username: username,
+		Level:    level,
+		Active:   true,
+	}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// InitAdmin creates admin user with given credentials when there are no users
+// at all, and reports whether it did so.
+func (r Repository) InitAdmin(username, password string) (bool, error) {
+
+	var count int64
+	if err := r.db.Model(&model.User{}).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count != 0 {
+		return false, nil
+	}
+
+	if _, err := r.NewUser(username, password, model.Admin); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r Repository) SetUserLevel(id int, level model.UserLevel) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user := model.User{}
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if user.Level == model.Admin && level != model.Admin {
+			if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("Level", level).Error
+	})
+}
+
+func (r Repository) SetUserPassword(id int, password string) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user := model.User{}
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("Password", user.Password).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+}
+
+func (r Repository) SetUserActive(id int, active bool) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user := model.User{}
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if !active && user.Level == model.Admin {
+			if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&user).Update("Active", active).Error; err != nil {
+			return err
+		}
+		if !active {
+			return revokeUserSessions(tx, user.ID)
+		}
+		return nil
+	})
+}
+
+// RemoveDefaultUsers deactivates seeded accounts which still use their
+// default password and returns their usernames. The last active admin is
+// kept, and ErrLastAdmin is returned once all other accounts are processed.
+func (r Repository) RemoveDefaultUsers() ([]string, error) {
+
+	removed := []string{}
+	var lastAdminErr error
+
+	for _, du := range DefaultUsers {
+		user := model.User{}
+		if err := r.UserByName(du.Username, &user); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if !user.Active || !user.CheckPassword(du.Username) {
+			continue
+		}
+		if err := r.SetUserActive(int(user.ID), false); err != nil {
+			if errors.Is(err, ErrLastAdmin) {
+				lastAdminErr = fmt.Errorf("user %s kept: %w", user.Username, err)
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, user.Username)
+	}
+
+	return removed, lastAdminErr
+}
+
+func ensureOtherActiveAdmin(tx *gorm.DB, id uint) error {
+	var count int64
+	if err := tx.Model(&model.User{}).
+		Where("Level = ? and Active = ? and ID <> ?", model.Admin, true, id).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&model.Session{}).Where("User_ID = ?", userID).Update("Revoked", true).Error
+}
@@ -2,16 +2,13 @@ package server
 
 import (
 	"errors"
-	"fmt"
-	"io"
-	"io/fs"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -32,13 +29,20 @@ func (s *Server) searchAudioRecords(ctx echo.Context) error {
 	var data []model.AudioRecording
 
 	var count int64
-	if err = s.repo.AudioRecordings(sp, &data, &count); err != nil {
+	next, err := s.repo.AudioRecordings(sp, &data, &count)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidSort) ||
+			errors.Is(err, repository.ErrInvalidCursor) ||
+			errors.Is(err, repository.ErrInvalidPage) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
 	ret := model.AudioRecordingsPagedResults{
-		PagedResults: model.PagedResults{count},
+		PagedResults: model.PagedResults{Count: count},
 		Data:         []model.AudioRecordingDTO{},
+		Next:         next,
 	}
 	for _, ar := range data {
 		ret.Data = append(ret.Data, ar.Map())
@@ -67,15 +71,8 @@ func (s *Server) deleteAudioRecord(ctx echo.Context) error {
 		return err
 	}
 
-	// remove files or not?
-	//absPath, err := filepath.Abs(ar.Path)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//if err = os.Remove(absPath); err != nil {
-	//	// do nothing, maybe log warning, as this should not happen
-	//}
+	// recording is in trash now, file is removed on purge
+	s.unindexRecording(ar.ID)
 
 	return ctx.NoContent(http.StatusOK)
 }
@@ -94,28 +91,35 @@ func (s *Server) createAudioRecord(ctx echo.Context) error {
 	}
 
 	name := ctx.FormValue("name")
-	client := ctx.FormValue("client")
+	clientID := ctx.FormValue("clientId")
 	comment := ctx.FormValue("comment")
 	category := ctx.FormValue("category")
-	duration := ctx.FormValue("duration")
 	active := ctx.FormValue("active")
 
-	file, err := ctx.FormFile("file")
+	bActive, err := strconv.ParseBool(active)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	bActive, err := strconv.ParseBool(active)
-	if err != nil {
+	validity := model.AudioRecording{}
+	if err := validity.SetValidity(cd.ValidFrom, cd.ValidTo); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// get source file
-	src, err := file.Open()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	var client *model.Client
+	if clientID != "" {
+		id, err := strconv.Atoi(clientID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		client = &model.Client{}
+		if err := s.repo.Client(id, client); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, repository.ErrUnknownClient.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 	}
-	defer src.Close()
 
 	// find matching category or default category
 	cat, err := s.repo.CategoryByName(category)
@@ -123,52 +127,40 @@ func (s *Server) createAudioRecord(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	destinationFileName := s.getAudioRecordingPath(file.Filename, cat.Path)
-
-	// create destination folder structure
-	destinationFolder := filepath.Dir(destinationFileName)
-	if err := os.MkdirAll(destinationFolder, fs.ModeDir); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	// create destination file
-	dest, err := os.Create(destinationFileName)
+	upload, err := s.storeUpload(ctx, cat.Path, 0)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	if _, err = io.Copy(dest, src); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
-	_ = dest.Close()
 
 	// create audio recording db record
-
-	// create duration
-	dur, err := time.ParseDuration(duration)
-	if err != nil {
-		// unable to parse duration, this should be treated seriously
-		_ = os.Remove(destinationFileName)
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-	_, destinationFileName = filepath.Split(destinationFileName)
 	ar := model.AudioRecording{
-		Name:     name,
-		Category: *cat,
-		Client:   &client,
-		Comment:  &comment,
-		Duration: dur,
-		Path:     filepath.Join(cat.Path, destinationFileName),
-		Date:     time.Now(),
-		Active:   bActive,
-	}
-
-	if err := s.repo.NewAudioRecording(&ar); err != nil {
-		_ = os.Remove(destinationFileName)
+		Name:       name,
+		Category:   *cat,
+		Client:     client,
+		Comment:    &comment,
+		Duration:   upload.Duration,
+		SampleRate: upload.Info.SampleRate,
+		Channels:   upload.Info.Channels,
+		Bitrate:    upload.Info.Bitrate,
+		Checksum:   upload.Checksum,
+		Size:       upload.Size,
+		Path:       upload.Path,
+		Date:       time.Now(),
+		Active:     bActive,
+		ValidFrom:  validity.ValidFrom,
+		ValidTo:    validity.ValidTo,
+	}
+
+	if err := s.repo.NewAudioRecording(&ar, currentSession(ctx).UserID); err != nil {
+		_ = os.Remove(upload.AbsPath)
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	s.indexRecording(ar)
 
-	return ctx.JSON(http.StatusCreated, ar.Map())
+	return ctx.JSON(http.StatusCreated, model.AudioRecordingCreatedDTO{
+		AudioRecordingDTO: ar.Map(),
+		Warnings:          upload.Warnings,
+	})
 }
 
 //func (s *Server) getCategoryByName(category string) Category {
@@ -183,14 +175,6 @@ func (s *Server) createAudioRecord(ctx echo.Context) error {
 //	return cat
 //}
 
-func (s *Server) getAudioRecordingPath(name, category string) string {
-	ext := filepath.Ext(name)
-	fileNameWoutExt := name[:len(name)-len(ext)]
-	cd := time.Now().Format("20060102150405")
-
-	return filepath.Join(s.Config.RootPath, category, fmt.Sprintf("%s-%s%s", fileNameWoutExt, cd, ext))
-}
-
 func (s *Server) getActiveAudioRecordingsForCategory(ctx echo.Context) error {
 
 	var err error
@@ -232,9 +216,39 @@ func (s *Server) updateAudioRecord(ctx echo.Context) error {
 
 	updated := model.AudioRecording{}
 	if err := s.repo.UpdateAudioRecording(id, &data, &updated); err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidValidity), errors.Is(err, repository.ErrUnknownClient):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	s.indexRecording(updated)
+
 	return ctx.JSON(http.StatusOK, updated.Map())
 
 }
+
+// audioRecordingDuplicates groups recordings by content checksum. Recordings
+// uploaded before checksums were introduced are left out until
+// `ozz-srv media check --update-checksums` records theirs.
+func (s *Server) audioRecordingDuplicates(ctx echo.Context) error {
+
+	data := []model.AudioRecording{}
+	if err := s.repo.DuplicateAudioRecordings(&data); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.DuplicateAudioRecordingsDTO{}
+	for _, ar := range data {
+		if len(res) == 0 || res[len(res)-1].Checksum != ar.Checksum {
+			res = append(res, model.DuplicateAudioRecordingsDTO{Checksum: ar.Checksum})
+		}
+		last := &res[len(res)-1]
+		last.Recordings = append(last.Recordings, ar.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
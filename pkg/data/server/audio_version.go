@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"ozz-ms/pkg/data/model"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func (s *Server) replaceAudioFile(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	rec := model.AudioRecording{}
+	if err := s.repo.AudioRecording(id, &rec); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// new version is stored next to the current one
+	upload, err := s.storeUpload(ctx, filepath.Dir(rec.Path), rec.ID)
+	if err != nil {
+		return err
+	}
+
+	userID := currentSession(ctx).UserID
+	version := model.AudioRecordingVersion{
+		Path:       upload.Path,
+		Duration:   upload.Duration,
+		SampleRate: upload.Info.SampleRate,
+		Channels:   upload.Info.Channels,
+		Bitrate:    upload.Info.Bitrate,
+		Checksum:   upload.Checksum,
+		Size:       upload.Size,
+		UserID:     &userID,
+	}
+
+	updated := model.AudioRecording{}
+	if err := s.repo.ReplaceAudioRecordingFile(id, version, &updated); err != nil {
+		_ = os.Remove(upload.AbsPath)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, model.AudioRecordingCreatedDTO{
+		AudioRecordingDTO: updated.Map(),
+		Warnings:          upload.Warnings,
+	})
+}
+
+func (s *Server) getAudioFileVersions(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	data := []model.AudioRecordingVersion{}
+	if err := s.repo.AudioRecordingVersions(id, &data); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.AudioRecordingVersionDTO{}
+	for _, version := range data {
+		res = append(res, version.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) restoreAudioFileVersion(ctx echo.Context) error {
+
+	var id, version int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).Int("version", &version).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	updated := model.AudioRecording{}
+	if err := s.repo.RestoreAudioRecordingVersion(id, version, &updated); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, updated.Map())
+}
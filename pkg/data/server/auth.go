@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	DefaultTokenTTL = 12 * time.Hour
+
+	sessionContextKey = "session"
+)
+
+type sessionClaims struct {
+	jwt.StandardClaims
+	Level int `json:"level"`
+}
+
+// authenticate validates the bearer token (or the token query parameter, used
+// by audio elements which cannot set headers) and stores the matching session
+// in the request context.
+func (s *Server) authenticate() echo.MiddlewareFunc {
+	return middleware.JWTWithConfig(middleware.JWTConfig{
+		TokenLookup: "header:" + echo.HeaderAuthorization + ",query:token",
+		ParseTokenFunc: func(auth string, ctx echo.Context) (interface{}, error) {
+			claims, err := s.parseToken(auth)
+			if err != nil {
+				return nil, err
+			}
+			session := model.Session{}
+			if err := s.repo.Session(claims.Id, &session); err != nil {
+				return nil, err
+			}
+			ctx.Set(sessionContextKey, &session)
+			return claims, nil
+		},
+		ErrorHandlerWithContext: func(err error, ctx echo.Context) error {
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				return echo.NewHTTPError(http.StatusUnauthorized, he.Message)
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		},
+	})
+}
+
+func (s *Server) issueToken(session *model.Session) (model.TokenDTO, error) {
+
+	claims := sessionClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        session.Token,
+			Subject:   strconv.Itoa(int(session.UserID)),
+			IssuedAt:  session.CreatedAt.Unix(),
+			ExpiresAt: session.ExpiresAt.Unix(),
+		},
+		Level: int(session.User.Level),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.tokenSecret)
+	if err != nil {
+		return model.TokenDTO{}, err
+	}
+
+	return model.TokenDTO{
+		Token:     signed,
+		ExpiresAt: session.ExpiresAt,
+		User:      session.User.Map(),
+	}, nil
+}
+
+func (s *Server) parseToken(auth string) (*sessionClaims, error) {
+	claims := &sessionClaims{}
+	token, err := jwt.ParseWithClaims(auth, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected token signing method")
+		}
+		return s.tokenSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func currentSession(ctx echo.Context) *model.Session {
+	session, _ := ctx.Get(sessionContextKey).(*model.Session)
+	return session
+}
+
+func (s *Server) authorize(ctx echo.Context) error {
+
+	var login model.LoginDTO
+
+	if err := ctx.Bind(&login); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&login); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user model.User
+	if err := s.repo.Authorize(login.Username, login.Password, &user); err != nil {
+		if errors.Is(err, repository.ErrInvalidCredentials) {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	session, err := s.repo.NewSession(user.ID, s.Config.TokenTTL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	token, err := s.issueToken(session)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, token)
+}
+
+func (s *Server) refreshToken(ctx echo.Context) error {
+
+	current := currentSession(ctx)
+
+	session, err := s.repo.NewSession(current.UserID, s.Config.TokenTTL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if err := s.repo.RevokeSession(current.Token); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	token, err := s.issueToken(session)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, token)
+}
+
+func (s *Server) logout(ctx echo.Context) error {
+
+	if err := s.repo.RevokeSession(currentSession(ctx).Token); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func newTokenSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// requireLevel rejects requests made by users below the given level. It must
+// be used on routes already guarded by authenticate.
+func requireLevel(level model.UserLevel) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			session := currentSession(ctx)
+			if session == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "not authenticated")
+			}
+			if session.User.Level < level {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions for this operation")
+			}
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func campaignError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrInvalidCampaignDates),
+		errors.Is(err, repository.ErrUnknownClient),
+		errors.Is(err, repository.ErrUnknownRecording):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrCampaignExists),
+		errors.Is(err, repository.ErrCampaignInUse),
+		errors.Is(err, repository.ErrSchedulesOutsideCampaign):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *Server) getCampaigns(ctx echo.Context) error {
+
+	sp := model.CampaignSearchParams{}
+	if err := ctx.Bind(&sp); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(sp); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	data := []model.Campaign{}
+	if err := s.repo.Campaigns(sp, &data); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.CampaignDTO{}
+	for _, campaign := range data {
+		res = append(res, campaign.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) getCampaign(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return s.campaignResponse(ctx, http.StatusOK, id)
+}
+
+func (s *Server) createCampaign(ctx echo.Context) error {
+
+	dto := model.NewCampaignDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	campaign, err := s.repo.NewCampaign(dto)
+	if err != nil {
+		return campaignError(err)
+	}
+
+	return ctx.JSON(http.StatusCreated, campaign.Map())
+}
+
+func (s *Server) updateCampaign(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dto := model.NewCampaignDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.SetCampaign(id, dto); err != nil {
+		return campaignError(err)
+	}
+
+	return s.campaignResponse(ctx, http.StatusOK, id)
+}
+
+func (s *Server) deleteCampaign(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.DeleteCampaign(id); err != nil {
+		return campaignError(err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (s *Server) getCampaignStatus(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	status, err := s.repo.CampaignStatus(id)
+	if err != nil {
+		return campaignError(err)
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+func (s *Server) campaignResponse(ctx echo.Context, status int, id int) error {
+	campaign := model.Campaign{}
+	if err := s.repo.Campaign(id, &campaign); err != nil {
+		return campaignError(err)
+	}
+	return ctx.JSON(status, campaign.Map())
+}
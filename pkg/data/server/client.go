@@ -0,0 +1,154 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func clientError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrClientExists), errors.Is(err, repository.ErrClientInUse):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrClientMergeSelf):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *Server) getClients(ctx echo.Context) error {
+
+	var name string
+	if err := echo.QueryParamsBinder(ctx).String("name", &name).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	data := []model.Client{}
+	if err := s.repo.Clients(name, &data); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.ClientDTO{}
+	for _, client := range data {
+		res = append(res, client.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) getClient(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return s.clientResponse(ctx, http.StatusOK, id)
+}
+
+func (s *Server) createClient(ctx echo.Context) error {
+
+	dto := model.ClientDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	client, err := s.repo.NewClient(dto)
+	if err != nil {
+		return clientError(err)
+	}
+
+	return ctx.JSON(http.StatusCreated, client.Map())
+}
+
+func (s *Server) updateClient(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dto := model.ClientDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.SetClient(id, dto); err != nil {
+		return clientError(err)
+	}
+	s.indexClientRecordings(uint(id))
+
+	return s.clientResponse(ctx, http.StatusOK, id)
+}
+
+func (s *Server) deleteClient(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.DeleteClient(id); err != nil {
+		return clientError(err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (s *Server) mergeClients(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dto := model.ClientMergeDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.MergeClients(id, dto.Sources); err != nil {
+		return clientError(err)
+	}
+	s.indexClientRecordings(uint(id))
+
+	return s.clientResponse(ctx, http.StatusOK, id)
+}
+
+func (s *Server) getClientDuplicates(ctx echo.Context) error {
+
+	res, err := s.repo.ClientDuplicates()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) clientResponse(ctx echo.Context, status int, id int) error {
+	client := model.Client{}
+	if err := s.repo.Client(id, &client); err != nil {
+		return clientError(err)
+	}
+	return ctx.JSON(status, client.Map())
+}
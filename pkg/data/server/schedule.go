@@ -5,11 +5,27 @@ import (
 	"net/http"
 
 	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
+func scheduleError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrOutsideValidity),
+		errors.Is(err, repository.ErrRecordingNotInCampaign),
+		errors.Is(err, repository.ErrOutsideCampaign):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrScheduledForCampaign):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
 func (s *Server) searchSchedules(ctx echo.Context) error {
 	var err error
 	ssp := model.ScheduleSearchParams{}
@@ -78,7 +94,7 @@ func (s *Server) createSchedule(ctx echo.Context) error {
 
 	sch, err := s.repo.NewSchedule(data)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return scheduleError(err)
 	}
 
 	return ctx.JSON(http.StatusOK, sch.Map())
@@ -100,7 +116,7 @@ func (s *Server) createMultipleSchedules(ctx echo.Context) error {
 		}
 		sch, err := s.repo.NewSchedule(sdto)
 		if err != nil {
-			return err
+			return scheduleError(err)
 		}
 		results = append(results, sch.Map())
 	}
@@ -143,10 +159,7 @@ func (s *Server) updateSchedule(ctx echo.Context) error {
 	}
 
 	if err := s.repo.SetSchedule(id, dto); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return echo.NewHTTPError(http.StatusNotFound, err.Error())
-		}
-		return err
+		return scheduleError(err)
 	}
 
 	sch := model.Schedule{}
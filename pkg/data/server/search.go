@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rebuildIndex indexes all recordings, so changes made while server was not
+// running, e.g. by cli tools, are picked up
+func (s *Server) rebuildIndex() error {
+	recordings := []model.AudioRecording{}
+	if err := s.repo.AllAudioRecordings(&recordings); err != nil {
+		return err
+	}
+	return s.index.Rebuild(recordings)
+}
+
+// indexRecording updates index after recording is created or changed. Index
+// failures do not fail the request, the database is already updated and the
+// index is rebuilt on next start.
+func (s *Server) indexRecording(rec model.AudioRecording) {
+	if err := s.index.Index(rec); err != nil {
+		s.es.Logger.Warnf("unable to index recording %d: %s", rec.ID, err)
+	}
+}
+
+func (s *Server) unindexRecording(id uint) {
+	if err := s.index.Delete(id); err != nil {
+		s.es.Logger.Warnf("unable to remove recording %d from index: %s", id, err)
+	}
+}
+
+// indexClientRecordings updates recordings after their client is renamed or
+// merged into another one
+func (s *Server) indexClientRecordings(clientID uint) {
+	recordings := []model.AudioRecording{}
+	if err := s.repo.ClientAudioRecordings(clientID, &recordings); err != nil {
+		s.es.Logger.Warnf("unable to index recordings of client %d: %s", clientID, err)
+		return
+	}
+	for _, rec := range recordings {
+		s.indexRecording(rec)
+	}
+}
+
+func (s *Server) fullTextSearchAudioRecords(ctx echo.Context) error {
+
+	sp := model.AudioRecordingTextSearchParams{}
+	if err := ctx.Bind(&sp); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(sp); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	count := repository.DefaultPageSize
+	if sp.Count != nil {
+		count = *sp.Count
+	}
+	skip := 0
+	if sp.Skip != nil {
+		skip = *sp.Skip
+	}
+	if count < 1 || count > repository.MaxPageSize || skip < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, repository.ErrInvalidPage.Error())
+	}
+
+	res, err := s.index.Search(sp.Query, skip, count)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	ids := []uint{}
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	data := []model.AudioRecording{}
+	if len(ids) > 0 {
+		if err := s.repo.AudioRecordingsByIDs(ids, &data); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	recordings := map[uint]model.AudioRecording{}
+	for _, rec := range data {
+		recordings[rec.ID] = rec
+	}
+
+	ret := model.AudioRecordingSearchResultsDTO{
+		PagedResults: model.PagedResults{Count: int64(res.Total)},
+		Data:         []model.AudioRecordingHitDTO{},
+	}
+	// keep index ranking
+	for _, hit := range res.Hits {
+		rec, ok := recordings[hit.ID]
+		if !ok {
+			continue
+		}
+		ret.Data = append(ret.Data, model.AudioRecordingHitDTO{
+			AudioRecordingDTO: rec.Map(),
+			Score:             hit.Score,
+			Highlights:        hit.Highlights,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, ret)
+}
@@ -7,9 +7,12 @@ import (
 	"reflect"
 	"time"
 
+	"ozz-ms/pkg/data/audio_index"
+	"ozz-ms/pkg/data/model"
 	"ozz-ms/pkg/data/repository"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 var (
@@ -18,17 +21,36 @@ var (
 )
 
 type ServerConfig struct {
-	Port     int
-	Dsn      string
-	Verbose  bool
-	RootPath string
+	Port        int
+	Dsn         string
+	Verbose     bool
+	RootPath    string
+	TokenSecret string
+	TokenTTL    time.Duration
+	// MaxUploadSize limits uploaded audio file size, e.g. 100M
+	MaxUploadSize string
+	// AllowedAudioTypes lists accepted upload types by extension, as
+	// detected from file content
+	AllowedAudioTypes []string
+	// IndexPath is location of recording full text index
+	IndexPath string
+	// AdminUsername and AdminPassword are used to create the first admin
+	// user on a database without users. Password is generated and logged
+	// when not set.
+	AdminUsername string
+	AdminPassword string
 }
 
 type Server struct {
 	Config ServerConfig
 	es     *echo.Echo
 	//db     *gorm.DB
-	repo *repository.Repository
+	repo        *repository.Repository
+	index       *audio_index.RecordingIndex
+	tokenSecret []byte
+
+	maxUploadSize     int64
+	allowedAudioTypes []string
 }
 
 func (s *Server) Start() error {
@@ -45,6 +67,25 @@ func (s *Server) Start() error {
 
 	s.repo = r
 
+	if err = s.initAdmin(); err != nil {
+		return err
+	}
+
+	if err = s.repo.PurgeExpiredSessions(); err != nil {
+		return err
+	}
+
+	indexPath := s.Config.IndexPath
+	if indexPath == "" {
+		indexPath = audio_index.DefaultIndexName
+	}
+	if s.index, err = audio_index.Open(indexPath); err != nil {
+		return err
+	}
+	if err = s.rebuildIndex(); err != nil {
+		return err
+	}
+
 	err = s.es.Start(fmt.Sprintf(":%d", s.Config.Port))
 	if err != nil {
 		return err
@@ -62,6 +103,10 @@ func (s *Server) Stop() error {
 		return err
 	}
 
+	if s.index != nil {
+		return s.index.Close()
+	}
+
 	return nil
 }
 
@@ -75,41 +120,110 @@ func NewDataServer(config ServerConfig) (*Server, error) {
 	ds.es.Validator = NewServerValidator()
 	ds.es.Binder = &ServerBinding{}
 
-	apiGroup := ds.es.Group("/api")
+	if ds.Config.AdminUsername == "" {
+		ds.Config.AdminUsername = DefaultAdminUsername
+	}
+	if ds.Config.TokenTTL <= 0 {
+		ds.Config.TokenTTL = DefaultTokenTTL
+	}
+	if ds.Config.TokenSecret != "" {
+		ds.tokenSecret = []byte(ds.Config.TokenSecret)
+	} else {
+		// no configured secret, tokens will not survive server restart
+		secret, err := newTokenSecret()
+		if err != nil {
+			return nil, err
+		}
+		ds.tokenSecret = secret
+	}
+
+	maxUploadSize, err := parseUploadSize(ds.Config.MaxUploadSize)
+	if err != nil {
+		return nil, err
+	}
+	ds.maxUploadSize = maxUploadSize
+	ds.allowedAudioTypes = ds.Config.AllowedAudioTypes
+	if len(ds.allowedAudioTypes) == 0 {
+		ds.allowedAudioTypes = DefaultAllowedAudioTypes
+	}
+	// leave room for other multipart form fields
+	uploadLimit := middleware.BodyLimit(fmt.Sprintf("%dK", maxUploadSize/1024+64))
+
+	ds.es.POST("/api/authorize", ds.authorize)
+
+	apiGroup := ds.es.Group("/api", ds.authenticate())
+	apiGroup.POST("/authorize/refresh", ds.refreshToken)
+	apiGroup.POST("/logout", ds.logout)
+
+	// regular users only work with dispositions; they may also fetch audio
+	// files and lookup tables needed to play and display them
+	adminOnly := requireLevel(model.Admin)
 
 	audioGroup := apiGroup.Group("/audio")
-	audioGroup.POST("", ds.createAudioRecord)
-	audioGroup.GET("", ds.searchAudioRecords)
-	audioGroup.PUT("/:id", ds.updateAudioRecord)
-	audioGroup.DELETE("/:id", ds.deleteAudioRecord)
+	audioGroup.POST("", ds.createAudioRecord, adminOnly, uploadLimit)
+	audioGroup.GET("", ds.searchAudioRecords, adminOnly)
+	audioGroup.GET("/search", ds.fullTextSearchAudioRecords, adminOnly)
+	audioGroup.PUT("/:id", ds.updateAudioRecord, adminOnly)
+	audioGroup.DELETE("/:id", ds.deleteAudioRecord, adminOnly)
 	audioGroup.GET("/media/:id", ds.serveAudioFile)
-	audioGroup.GET("/log", ds.audioRecordingLog)
+	audioGroup.GET("/log", ds.audioRecordingLog, adminOnly)
+	audioGroup.GET("/duplicates", ds.audioRecordingDuplicates, adminOnly)
+	audioGroup.GET("/trash", ds.getTrashedAudioRecords, adminOnly)
+	audioGroup.POST("/:id/restore", ds.restoreAudioRecord, adminOnly)
+	audioGroup.DELETE("/:id/purge", ds.purgeAudioRecord, adminOnly)
+	audioGroup.PUT("/:id/file", ds.replaceAudioFile, adminOnly, uploadLimit)
+	audioGroup.GET("/:id/versions", ds.getAudioFileVersions, adminOnly)
+	audioGroup.POST("/:id/versions/:version/restore", ds.restoreAudioFileVersion, adminOnly)
 	//audioGroup.GET("/active/:id", ds.getActiveAudioRecordingsForCategory)
 
+	campaignGroup := apiGroup.Group("/campaigns")
+	campaignGroup.GET("", ds.getCampaigns, adminOnly)
+	campaignGroup.GET("/:id", ds.getCampaign, adminOnly)
+	campaignGroup.GET("/:id/status", ds.getCampaignStatus, adminOnly)
+	campaignGroup.POST("", ds.createCampaign, adminOnly)
+	campaignGroup.PUT("/:id", ds.updateCampaign, adminOnly)
+	campaignGroup.DELETE("/:id", ds.deleteCampaign, adminOnly)
+
 	scheduleGroup := apiGroup.Group("/schedules")
-	scheduleGroup.GET("", ds.searchSchedules)
-	scheduleGroup.GET("/:id", ds.getSchedule)
-	scheduleGroup.PUT("/:id", ds.updateSchedule)
-	scheduleGroup.DELETE("/:id", ds.deleteSchedule)
-	scheduleGroup.POST("", ds.createSchedule)
-	scheduleGroup.POST("/multiple", ds.createMultipleSchedules)
+	scheduleGroup.GET("", ds.searchSchedules, adminOnly)
+	scheduleGroup.GET("/:id", ds.getSchedule, adminOnly)
+	scheduleGroup.PUT("/:id", ds.updateSchedule, adminOnly)
+	scheduleGroup.DELETE("/:id", ds.deleteSchedule, adminOnly)
+	scheduleGroup.POST("", ds.createSchedule, adminOnly)
+	scheduleGroup.POST("/multiple", ds.createMultipleSchedules, adminOnly)
 
 	dispositionGroup := apiGroup.Group("/dispositions")
 	dispositionGroup.GET("", ds.searchDispositions)
-	dispositionGroup.POST("/create", ds.createDispositions)
+	dispositionGroup.POST("/create", ds.createDispositions, adminOnly)
 	dispositionGroup.POST("/mark", ds.markDispositionExecution)
 	//dispositionGroup.POST("/:id/increase", ds.increaseDispositionPlayedCount)
 	//dispositionGroup.POST("/:id/decrease", ds.decreaseDispositionPlayedCount)
 
 	equalizerGroup := apiGroup.Group("/equalizers")
-	equalizerGroup.GET("", ds.getEqualizers)
-	equalizerGroup.GET("/:id", ds.getEqualizer)
-	equalizerGroup.POST("", ds.createEqualizer)
-	equalizerGroup.PUT("/:id", ds.updateEqualizer)
-	equalizerGroup.DELETE("/:id", ds.deleteEqualizer)
+	equalizerGroup.GET("", ds.getEqualizers, adminOnly)
+	equalizerGroup.GET("/:id", ds.getEqualizer, adminOnly)
+	equalizerGroup.POST("", ds.createEqualizer, adminOnly)
+	equalizerGroup.PUT("/:id", ds.updateEqualizer, adminOnly)
+	equalizerGroup.DELETE("/:id", ds.deleteEqualizer, adminOnly)
+
+	clientGroup := apiGroup.Group("/clients")
+	clientGroup.GET("", ds.getClients, adminOnly)
+	clientGroup.GET("/duplicates", ds.getClientDuplicates, adminOnly)
+	clientGroup.GET("/:id", ds.getClient, adminOnly)
+	clientGroup.POST("", ds.createClient, adminOnly)
+	clientGroup.PUT("/:id", ds.updateClient, adminOnly)
+	clientGroup.DELETE("/:id", ds.deleteClient, adminOnly)
+	clientGroup.POST("/:id/merge", ds.mergeClients, adminOnly)
+
+	userGroup := apiGroup.Group("/users")
+	userGroup.GET("", ds.getUsers, adminOnly)
+	userGroup.POST("", ds.createUser, adminOnly)
+	userGroup.PUT("/me/password", ds.changeOwnPassword)
+	userGroup.PUT("/:id/level", ds.updateUserLevel, adminOnly)
+	userGroup.PUT("/:id/password", ds.resetUserPassword, adminOnly)
+	userGroup.DELETE("/:id", ds.deactivateUser, adminOnly)
 
 	apiGroup.GET("/shifts", ds.getShifts)
-	apiGroup.POST("/authorize", ds.authorize)
 	apiGroup.GET("/categories", ds.getCategories)
 
 	return ds, nil
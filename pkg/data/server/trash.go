@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func trashError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrNotInTrash), errors.Is(err, repository.ErrNameConflict):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *Server) getTrashedAudioRecords(ctx echo.Context) error {
+
+	data := []model.AudioRecording{}
+	if err := s.repo.TrashedAudioRecordings(&data); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.AudioRecordingDTO{}
+	for _, ar := range data {
+		res = append(res, ar.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) restoreAudioRecord(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	restored := model.AudioRecording{}
+	if err := s.repo.RestoreAudioRecording(id, &restored); err != nil {
+		return trashError(err)
+	}
+
+	s.indexRecording(restored)
+
+	return ctx.JSON(http.StatusOK, restored.Map())
+}
+
+func (s *Server) purgeAudioRecord(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	paths, err := s.repo.PurgeAudioRecording(id)
+	if err != nil {
+		return trashError(err)
+	}
+
+	res := model.PurgedAudioRecordingDTO{
+		RemovedFiles: []string{},
+	}
+	for _, path := range paths {
+		if err := os.Remove(filepath.Join(s.Config.RootPath, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			// database record is gone already, report file and go on
+			res.FailedFiles = append(res.FailedFiles, path)
+			continue
+		}
+		res.RemovedFiles = append(res.RemovedFiles, path)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ozz-ms/pkg/audio_info"
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/util"
+
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/types"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/bytes"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultMaxUploadSize = "100M"
+
+	// number of bytes needed by filetype to recognize all supported types
+	sniffHeaderSize = 261
+)
+
+var DefaultAllowedAudioTypes = []string{"mp3", "wav", "flac", "ogg", "m4a", "aac"}
+
+func parseUploadSize(size string) (int64, error) {
+	if size == "" {
+		size = DefaultMaxUploadSize
+	}
+	limit, err := bytes.Parse(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upload size limit: %w", err)
+	}
+	if limit <= 0 {
+		return 0, fmt.Errorf("invalid upload size limit: %s", size)
+	}
+	return limit, nil
+}
+
+// checkUpload rejects oversized uploads and content which is not one of the
+// allowed audio types. Source is rewound, so it can be copied afterwards.
+func (s *Server) checkUpload(file *multipart.FileHeader, src multipart.File) (types.Type, error) {
+
+	if file.Size > s.maxUploadSize {
+		return types.Unknown, echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("file is larger than %s", bytes.Format(s.maxUploadSize)))
+	}
+
+	header := make([]byte, sniffHeaderSize)
+	n, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return types.Unknown, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return types.Unknown, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	kind, err := filetype.Match(header[:n])
+	if err != nil || !filetype.IsAudio(header[:n]) {
+		return types.Unknown, echo.NewHTTPError(http.StatusUnsupportedMediaType, "uploaded file is not recognized as audio")
+	}
+
+	for _, allowed := range s.allowedAudioTypes {
+		if strings.EqualFold(allowed, kind.Extension) {
+			return kind, nil
+		}
+	}
+
+	return types.Unknown, echo.NewHTTPError(http.StatusUnsupportedMediaType,
+		fmt.Sprintf("audio type %s is not allowed, allowed types: %s", kind.Extension, strings.Join(s.allowedAudioTypes, ", ")))
+}
+
+type uploadedAudio struct {
+	// Path relative to media root
+	Path     string
+	AbsPath  string
+	Info     audio_info.Info
+	Duration time.Duration
+	Checksum string
+	Size     int64
+	Warnings []string
+}
+
+// storeUpload checks uploaded "file" form field, stores it under category
+// folder and measures it. Upload with content of another recording is
+// rejected with conflict, unless "force" is set; recording excludeID is not
+// treated as duplicate of itself.
+func (s *Server) storeUpload(ctx echo.Context, categoryPath string, excludeID uint) (*uploadedAudio, error) {
+
+	var err error
+
+	duration := ctx.FormValue("duration")
+	force := ctx.FormValue("force")
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bForce := false
+	if force != "" {
+		if bForce, err = strconv.ParseBool(force); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	// client supplied duration is optional, file content is measured anyway
+	var clientDuration *time.Duration
+	if duration != "" {
+		dur, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		clientDuration = &dur
+	}
+
+	// get source file
+	src, err := file.Open()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer src.Close()
+
+	// check content before anything is written to media root
+	kind, err := s.checkUpload(file, src)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := util.Checksum(src)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// same content already uploaded?
+	if !bForce {
+		existing := model.AudioRecording{}
+		err := s.repo.AudioRecordingByChecksum(checksum, excludeID, &existing)
+		if err == nil {
+			return nil, echo.NewHTTPError(http.StatusConflict, model.DuplicateAudioRecordingDTO{
+				Message:  fmt.Sprintf("same audio content is already uploaded as %s", existing.Name),
+				Existing: existing.Map(),
+			})
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	destinationFileName := s.getAudioRecordingPath(file.Filename, categoryPath, kind.Extension)
+
+	// create destination folder structure
+	destinationFolder := filepath.Dir(destinationFileName)
+	if err := os.MkdirAll(destinationFolder, fs.ModeDir); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// create destination file
+	dest, err := os.Create(destinationFileName)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	size, err := io.Copy(dest, src)
+	if err != nil {
+		_ = dest.Close()
+		_ = os.Remove(destinationFileName)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	// measure stored file
+	info, probeErr := probeAudioFile(dest)
+	_ = dest.Close()
+
+	upload := uploadedAudio{
+		AbsPath:  destinationFileName,
+		Checksum: checksum,
+		Size:     size,
+		Warnings: []string{},
+	}
+
+	switch {
+	case probeErr == nil:
+		upload.Info = *info
+		upload.Duration = info.Duration
+		if clientDuration != nil && absDuration(*clientDuration-info.Duration) > durationMismatchTolerance {
+			upload.Warnings = append(upload.Warnings, fmt.Sprintf("supplied duration %s differs from measured duration %s, measured one is used", *clientDuration, info.Duration))
+		}
+	case clientDuration != nil:
+		upload.Duration = *clientDuration
+		upload.Warnings = append(upload.Warnings, fmt.Sprintf("unable to measure audio file, supplied duration is used: %s", probeErr))
+	default:
+		_ = os.Remove(destinationFileName)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unable to measure audio file and no duration supplied: %s", probeErr))
+	}
+
+	_, fileName := filepath.Split(destinationFileName)
+	upload.Path = filepath.Join(categoryPath, fileName)
+
+	return &upload, nil
+}
+
+// durationMismatchTolerance is the largest difference between supplied and
+// measured duration accepted without warning
+const durationMismatchTolerance = 500 * time.Millisecond
+
+func probeAudioFile(f *os.File) (*audio_info.Info, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return audio_info.Probe(f)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// getAudioRecordingPath creates storage path for uploaded file. Only base
+// name of the uploaded file is used, and its extension is replaced with the
+// one matching detected content. Existing files are never reused.
+func (s *Server) getAudioRecordingPath(name, category, extension string) string {
+	name = filepath.Base(filepath.Clean("/" + filepath.ToSlash(name)))
+	ext := filepath.Ext(name)
+	fileNameWoutExt := name[:len(name)-len(ext)]
+	cd := time.Now().Format("20060102150405")
+
+	candidate := filepath.Join(s.Config.RootPath, category, fmt.Sprintf("%s-%s.%s", fileNameWoutExt, cd, extension))
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+		candidate = filepath.Join(s.Config.RootPath, category, fmt.Sprintf("%s-%s-%d.%s", fileNameWoutExt, cd, i, extension))
+	}
+}
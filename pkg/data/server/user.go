@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"ozz-ms/pkg/data/model"
+	"ozz-ms/pkg/data/repository"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const DefaultAdminUsername = "admin"
+
+// initAdmin creates configured admin user on a database without users
+func (s *Server) initAdmin() error {
+	password := s.Config.AdminPassword
+	generated := password == ""
+	if generated {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	created, err := s.repo.InitAdmin(s.Config.AdminUsername, password)
+	if err != nil || !created {
+		return err
+	}
+	if generated {
+		s.es.Logger.Warnf("created admin user %s with password %s, change it after first login", s.Config.AdminUsername, password)
+	} else {
+		s.es.Logger.Infof("created admin user %s", s.Config.AdminUsername)
+	}
+	return nil
+}
+
+func userError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrUserExists), errors.Is(err, repository.ErrLastAdmin):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *Server) getUsers(ctx echo.Context) error {
+
+	data := []model.User{}
+	if err := s.repo.Users(&data); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := []model.UserDTO{}
+	for _, user := range data {
+		res = append(res, user.Map())
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (s *Server) createUser(ctx echo.Context) error {
+
+	dto := model.NewUserDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := s.repo.NewUser(dto.Username, dto.Password, model.UserLevel(dto.Level))
+	if err != nil {
+		return userError(err)
+	}
+
+	return ctx.JSON(http.StatusCreated, user.Map())
+}
+
+func (s *Server) updateUserLevel(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dto := model.UserLevelDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.SetUserLevel(id, model.UserLevel(dto.Level)); err != nil {
+		return userError(err)
+	}
+
+	return s.userResponse(ctx, id)
+}
+
+func (s *Server) resetUserPassword(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	dto := model.UserPasswordDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.SetUserPassword(id, dto.Password); err != nil {
+		return userError(err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (s *Server) deactivateUser(ctx echo.Context) error {
+
+	var id int
+	if err := echo.PathParamsBinder(ctx).Int("id", &id).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.repo.SetUserActive(id, false); err != nil {
+		return userError(err)
+	}
+
+	return s.userResponse(ctx, id)
+}
+
+func (s *Server) changeOwnPassword(ctx echo.Context) error {
+
+	dto := model.ChangePasswordDTO{}
+	if err := ctx.Bind(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := ctx.Validate(&dto); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	session := currentSession(ctx)
+	if !session.User.CheckPassword(dto.OldPassword) {
+		return echo.NewHTTPError(http.StatusForbidden, repository.ErrInvalidCredentials.Error())
+	}
+
+	if err := s.repo.SetUserPassword(int(session.UserID), dto.Password); err != nil {
+		return userError(err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (s *Server) userResponse(ctx echo.Context, id int) error {
+	user := model.User{}
+	if err := s.repo.User(id, &user); err != nil {
+		return userError(err)
+	}
+	return ctx.JSON(http.StatusOK, user.Map())
+}
@@ -0,0 +1,133 @@
+package media_index
+
+import (
+	"bytes"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	regexpfilter "github.com/blevesearch/bleve/v2/analysis/char/regexp"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+const (
+	// SerbianAnalyzerName splits file names into words, lowercases,
+	// transliterates cyrillic to latin and folds serbian diacritics, so
+	// "ŠPICE", "špice", "ШПИЦЕ" and "spice" all produce the same term
+	SerbianAnalyzerName = "serbian"
+	// SerbianSuggestAnalyzerName analyzes like SerbianAnalyzerName, and
+	// indexes all word prefixes, for type-ahead suggestions
+	SerbianSuggestAnalyzerName = "serbian_suggest"
+	// SerbianFoldFilterName is name of token filter doing transliteration and
+	// folding, it expects lowercased input
+	SerbianFoldFilterName = "serbian_fold"
+)
+
+// nameSeparators are separators used in file names, tokenizer would otherwise
+// keep words like "jutro.mp3" or "spot_v2" together
+var nameSeparators = regexp.MustCompile(`[._]`)
+
+// serbianFold maps lowercase cyrillic and latin letters with diacritics to
+// their plain latin form
+var serbianFold = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'ђ': "dj",
+	'е': "e", 'ж': "z", 'з': "z", 'и': "i", 'ј': "j", 'к': "k",
+	'л': "l", 'љ': "lj", 'м': "m", 'н': "n", 'њ': "nj", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'ћ': "c", 'у': "u",
+	'ф': "f", 'х': "h", 'ц': "c", 'ч': "c", 'џ': "dz", 'ш': "s",
+	'š': "s", 'ž': "z", 'ć': "c", 'č': "c", 'đ': "dj",
+}
+
+type SerbianFoldFilter struct {
+}
+
+func NewSerbianFoldFilter() *SerbianFoldFilter {
+	return &SerbianFoldFilter{}
+}
+
+func (f *SerbianFoldFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = foldSerbian(token.Term)
+	}
+	return input
+}
+
+func foldSerbian(term []byte) []byte {
+	// most terms are plain ascii, leave them untouched
+	needsFolding := false
+	for _, b := range term {
+		if b >= utf8.RuneSelf {
+			needsFolding = true
+			break
+		}
+	}
+	if !needsFolding {
+		return term
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(term))
+	for _, r := range string(term) {
+		if s, ok := serbianFold[r]; ok {
+			buf.WriteString(s)
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.Bytes()
+}
+
+func SerbianFoldFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewSerbianFoldFilter(), nil
+}
+
+func SerbianAnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	tokenizer, err := cache.TokenizerNamed(unicode.Name)
+	if err != nil {
+		return nil, err
+	}
+	toLowerFilter, err := cache.TokenFilterNamed(lowercase.Name)
+	if err != nil {
+		return nil, err
+	}
+	foldFilter, err := cache.TokenFilterNamed(SerbianFoldFilterName)
+	if err != nil {
+		return nil, err
+	}
+	rv := analysis.Analyzer{
+		CharFilters: []analysis.CharFilter{
+			regexpfilter.New(nameSeparators, []byte(" ")),
+		},
+		Tokenizer: tokenizer,
+		TokenFilters: []analysis.TokenFilter{
+			toLowerFilter,
+			foldFilter,
+		},
+	}
+	return &rv, nil
+}
+
+// suggest prefixes are indexed from minSuggestPrefix letters, to
+// maxSuggestPrefix letters of a word
+const (
+	minSuggestPrefix = 2
+	maxSuggestPrefix = 25
+)
+
+func SerbianSuggestAnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	analyzer, err := SerbianAnalyzerConstructor(config, cache)
+	if err != nil {
+		return nil, err
+	}
+	analyzer.TokenFilters = append(analyzer.TokenFilters,
+		edgengram.NewEdgeNgramFilter(edgengram.FRONT, minSuggestPrefix, maxSuggestPrefix))
+	return analyzer, nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(SerbianFoldFilterName, SerbianFoldFilterConstructor)
+	registry.RegisterAnalyzer(SerbianAnalyzerName, SerbianAnalyzerConstructor)
+	registry.RegisterAnalyzer(SerbianSuggestAnalyzerName, SerbianSuggestAnalyzerConstructor)
+}
@@ -2,20 +2,43 @@ package media_index
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gosuri/uitable"
 )
 
+// AudioFileType is document type audio files are indexed with
+const AudioFileType = "audio"
+
 type AudioFile struct {
-	ID       string
-	Path     string
-	Folder   string
-	Root     string
-	Name     string
-	Artist   string
-	Album    string
-	Duration string
-	Tags     []string
+	ID string
+	// Fingerprint identifies content of file, see fingerprint
+	Fingerprint string
+	Path        string
+	Folder      string
+	Root        string
+	Name        string
+	Title       string
+	Artist      string
+	Album       string
+	Genre       string
+	Year        int
+	Track       int
+	// Duration is display duration, as "0:03:25"
+	Duration   string
+	DurationMs int64
+	// Bitrate in kbps
+	Bitrate    int
+	SampleRate int
+	Channels   int
+	Tags       []string
+	Size       int64
+	ModTime    time.Time
+}
+
+// BleveType makes index use audio mapping for audio files
+func (f AudioFile) BleveType() string {
+	return AudioFileType
 }
 
 type AudioFiles []AudioFile
@@ -29,9 +52,13 @@ func (f AudioFiles) WriteOut() {
 		table.AddRow("Name:", item.Name)
 		table.AddRow("Path:", item.Path)
 		table.AddRow("Folder:", item.Folder)
+		table.AddRow("Title:", item.Title)
 		table.AddRow("Artist:", item.Artist)
 		table.AddRow("Album:", item.Album)
+		table.AddRow("Genre:", item.Genre)
+		table.AddRow("Year:", item.Year)
 		table.AddRow("Duration:", item.Duration)
+		table.AddRow("Audio:", fmt.Sprintf("%d kbps, %d Hz, %d ch", item.Bitrate, item.SampleRate, item.Channels))
 		table.AddRow("")
 	}
 	fmt.Println(table)
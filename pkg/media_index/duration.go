@@ -0,0 +1,129 @@
+package media_index
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// durationClause matches duration ranges in query string, as in
+// "duration:<30s", "+duration:>=1m30s", "-duration:3m..5m" or
+// "duration:2:30.."
+var durationClause = regexp.MustCompile(`(?i)(^|\s)([+-]?)duration:(<=|>=|<|>)?([^\s"]*\.\.[^\s"]*|[^\s"]+)`)
+
+// parseDurationValue parses duration written as Go duration ("1m30s"),
+// clock time ("1:30", "0:01:30") or number of seconds ("90")
+func parseDurationValue(value string) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	if strings.Contains(value, ":") {
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		var d time.Duration
+		for _, part := range parts {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %s", value)
+			}
+			d = d*60 + time.Duration(n*float64(time.Second))
+		}
+		return d, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+	return d, nil
+}
+
+// durationRange creates query for files with duration in range, files of
+// unknown duration never match
+func durationRange(op string, value string) (query.Query, error) {
+	ms := func(s string) (*float64, error) {
+		if s == "" {
+			return nil, nil
+		}
+		d, err := parseDurationValue(s)
+		if err != nil {
+			return nil, err
+		}
+		return bound(float64(d.Milliseconds())), nil
+	}
+	var min, max *float64
+	minInclusive, maxInclusive := true, true
+	var err error
+	switch op {
+	case "<", "<=":
+		min = bound(1)
+		max, err = ms(value)
+		maxInclusive = op == "<="
+	case ">", ">=":
+		min, err = ms(value)
+		minInclusive = op == ">="
+	default:
+		bounds := strings.SplitN(value, "..", 2)
+		if min, err = ms(bounds[0]); err == nil {
+			max, err = ms(bounds[1])
+		}
+		if min == nil {
+			min = bound(1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if op != "" && max == nil && min == nil {
+		return nil, fmt.Errorf("missing duration after %s", op)
+	}
+	qr := bleve.NewNumericRangeInclusiveQuery(min, max, &minInclusive, &maxInclusive)
+	qr.SetField("DurationMs")
+	return qr, nil
+}
+
+// parseQuery parses query string with duration ranges. Ranges are required
+// unless prefixed with "-", when files in range are left out. Other
+// "duration:" clauses are passed to query string as they are.
+func parseQuery(term string) (query.Query, error) {
+	var must, mustNot []query.Query
+	var rangeErr error
+	rest := durationClause.ReplaceAllStringFunc(term, func(clause string) string {
+		m := durationClause.FindStringSubmatch(clause)
+		lead, sign, op, value := m[1], m[2], m[3], m[4]
+		if op == "" && !strings.Contains(value, "..") {
+			return clause
+		}
+		qr, err := durationRange(op, value)
+		if err != nil {
+			if rangeErr == nil {
+				rangeErr = fmt.Errorf("%w: %s", ErrInvalidSearch, err)
+			}
+			return clause
+		}
+		if sign == "-" {
+			mustNot = append(mustNot, qr)
+		} else {
+			must = append(must, qr)
+		}
+		return lead
+	})
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+	if len(must) == 0 && len(mustNot) == 0 {
+		return bleve.NewQueryStringQuery(term), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		must = append(must, bleve.NewQueryStringQuery(rest))
+	} else {
+		must = append(must, bleve.NewMatchAllQuery())
+	}
+	return query.NewBooleanQuery(must, nil, mustNot), nil
+}
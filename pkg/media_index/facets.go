@@ -0,0 +1,99 @@
+package media_index
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// FacetSize is maximum number of values returned for genre and folder
+var FacetSize = 20
+
+// FacetCount is number of hits having facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// numericBucket is named range of numeric facet, min is inclusive and max
+// exclusive
+type numericBucket struct {
+	name string
+	min  *float64
+	max  *float64
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// durationBuckets start at one millisecond, leaving out files of unknown
+// duration
+var durationBuckets = []numericBucket{
+	{"under 30s", bound(1), bound(30e3)},
+	{"30s - 1m", bound(30e3), bound(60e3)},
+	{"1m - 3m", bound(60e3), bound(180e3)},
+	{"3m - 5m", bound(180e3), bound(300e3)},
+	{"5m - 10m", bound(300e3), bound(600e3)},
+	{"over 10m", bound(600e3), nil},
+}
+
+// yearBuckets are decades up to current one
+func yearBuckets() []numericBucket {
+	buckets := []numericBucket{{"before 1950", bound(1), bound(1950)}}
+	for decade := 1950; decade <= time.Now().Year(); decade += 10 {
+		buckets = append(buckets, numericBucket{fmt.Sprintf("%ds", decade), bound(float64(decade)), bound(float64(decade + 10))})
+	}
+	return buckets
+}
+
+func numericFacet(field string, buckets []numericBucket) *bleve.FacetRequest {
+	facet := bleve.NewFacetRequest(field, len(buckets))
+	for _, b := range buckets {
+		facet.AddNumericRange(b.name, b.min, b.max)
+	}
+	return facet
+}
+
+func addFacets(req *bleve.SearchRequest) {
+	req.AddFacet("genre", bleve.NewFacetRequest(facetFields["Genre"], FacetSize))
+	req.AddFacet("folder", bleve.NewFacetRequest(facetFields["Folder"], FacetSize))
+	req.AddFacet("year", numericFacet("Year", yearBuckets()))
+	req.AddFacet("duration", numericFacet("DurationMs", durationBuckets))
+}
+
+// facetCounts converts facet results, numeric ranges are kept in order they
+// are defined in, and ranges without hits are left out
+func facetCounts(results search.FacetResults) map[string][]FacetCount {
+	counts := map[string][]FacetCount{}
+	for name, result := range results {
+		values := []FacetCount{}
+		if result.Terms != nil {
+			for _, term := range result.Terms.Terms() {
+				if term.Term == "" {
+					// files without the tag
+					continue
+				}
+				values = append(values, FacetCount{Value: term.Term, Count: term.Count})
+			}
+		}
+		var buckets []numericBucket
+		switch name {
+		case "year":
+			buckets = yearBuckets()
+		case "duration":
+			buckets = durationBuckets
+		}
+		for _, b := range buckets {
+			for _, r := range result.NumericRanges {
+				if r.Name == b.name && r.Count > 0 {
+					values = append(values, FacetCount{Value: r.Name, Count: r.Count})
+				}
+			}
+		}
+		counts[name] = values
+	}
+	return counts
+}
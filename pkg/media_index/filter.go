@@ -0,0 +1,93 @@
+package media_index
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WalkFilter selects files and folders indexed under roots. Patterns are
+// globs as in path.Match; patterns with "/" match path relative to root, as
+// "exports/tmp*", and others match name, as "*.bak" or ".Trash*".
+type WalkFilter struct {
+	// Include lists patterns of files indexed, all files if empty
+	Include []string
+	// Exclude lists patterns of files and folders not indexed
+	Exclude []string
+	// MaxDepth limits folder levels walked, files directly in root are on
+	// level 1; unlimited if not set
+	MaxDepth int
+	// FollowSymlinks walks linked files and folders, links are skipped
+	// otherwise
+	FollowSymlinks bool
+	// Hidden indexes files and folders with names starting with dot
+	Hidden bool
+}
+
+// Validate checks filter patterns
+func (f WalkFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if f.MaxDepth < 0 {
+		return fmt.Errorf("invalid max depth %d", f.MaxDepth)
+	}
+	return nil
+}
+
+// Accepts reports whether file, or folder, at lpath found under root is
+// indexed. Links are not checked here, as lpath is not read.
+func (f WalkFilter) Accepts(root string, lpath string, isDir bool) bool {
+	rel, err := filepath.Rel(root, lpath)
+	if err != nil || rel == "." {
+		// root itself
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	name := path.Base(rel)
+
+	if !f.Hidden && strings.HasPrefix(name, ".") {
+		return false
+	}
+	if f.MaxDepth > 0 {
+		depth := strings.Count(rel, "/") + 1
+		if depth > f.MaxDepth || isDir && depth == f.MaxDepth {
+			return false
+		}
+	}
+	if matchAny(f.Exclude, rel, name) {
+		return false
+	}
+	if !isDir && len(f.Include) > 0 {
+		return matchAny(f.Include, rel, name)
+	}
+	return true
+}
+
+func matchAny(patterns []string, rel string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsTree reports whether lpath, and all folders between root and lpath,
+// are accepted
+func (f WalkFilter) acceptsTree(root string, lpath string, isDir bool) bool {
+	croot := filepath.Clean(root)
+	for dir := filepath.Dir(lpath); dir != croot && strings.HasPrefix(dir, croot); dir = filepath.Dir(dir) {
+		if !f.Accepts(root, dir, true) {
+			return false
+		}
+	}
+	return f.Accepts(root, lpath, isDir)
+}
@@ -0,0 +1,161 @@
+package media_index
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"ozz-ms/pkg/audio_info"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// fingerprintChunk is size of payload parts hashed for fingerprint, whole
+// payload is hashed when it is not larger than three chunks
+const fingerprintChunk = 64 * 1024
+
+// fingerprint identifies audio content of file as payload size and hash.
+// Payload is encoded audio without tags and container metadata, see
+// audio_info.Payload, so retagged file keeps fingerprint. Ogg file keeps it
+// only while its tags fit same number of pages. Larger files are hashed at
+// start, middle and end of payload.
+func fingerprint(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	start, end, err := audio_info.Payload(f)
+	if err != nil {
+		// damaged container, whole file is hashed
+		start, end = 0, size
+	}
+	payload := end - start
+
+	hs := md5.New()
+	if payload <= 3*fingerprintChunk {
+		if _, err := io.Copy(hs, io.NewSectionReader(f, start, payload)); err != nil {
+			return "", err
+		}
+	} else {
+		for _, offset := range []int64{start, start + payload/2, end - fingerprintChunk} {
+			if _, err := io.Copy(hs, io.NewSectionReader(f, offset, fingerprintChunk)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return fmt.Sprintf("%x-%x", payload, hs.Sum(nil)), nil
+}
+
+// AddFile adds file found on disk to index. File keeps ID it is indexed
+// with at same path, or, when it was moved, ID of file with same content no
+// longer found at its path. New files get their fingerprint as ID, and copies
+// of already indexed files fingerprint and path hash. It reports whether file
+// was moved.
+func (i *MediaIndex) AddFile(af *AudioFile) (bool, error) {
+	same, err := i.sameFiles(*af)
+	if err != nil {
+		return false, err
+	}
+	moved := false
+	af.ID = ""
+	for _, item := range same {
+		if item.Path == af.Path {
+			af.ID = item.ID
+			break
+		}
+	}
+	if af.ID == "" {
+		for _, item := range same {
+			if item.Fingerprint != af.Fingerprint || i.pending[item.ID] != "" {
+				continue
+			}
+			if _, err := os.Stat(item.Path); os.IsNotExist(err) {
+				af.ID = item.ID
+				moved = true
+				break
+			}
+		}
+	}
+	if af.ID == "" {
+		af.ID = af.Fingerprint
+		if i.idTaken(af.ID, af.Path) {
+			hs, err := createHash(af.Path)
+			if err != nil {
+				return false, err
+			}
+			af.ID = af.Fingerprint + "-" + hs[:8]
+		}
+	}
+	if err := i.AddItem(*af); err != nil {
+		return false, err
+	}
+	if i.batchCount > 0 {
+		if i.pending == nil {
+			i.pending = map[string]string{}
+		}
+		i.pending[af.ID] = af.Path
+	}
+	return moved, nil
+}
+
+// sameFiles returns indexed files at path of af, or with same content
+func (i *MediaIndex) sameFiles(af AudioFile) (AudioFiles, error) {
+	byPath := bleve.NewTermQuery(af.Path)
+	byPath.SetField("Path")
+	byContent := bleve.NewTermQuery(af.Fingerprint)
+	byContent.SetField("Fingerprint")
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(byPath, byContent), BatchSize, 0, false)
+	req.Fields = []string{"Path", "Fingerprint"}
+	res, err := i.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	var ret AudioFiles
+	for _, hit := range res.Hits {
+		ret = append(ret, searchHit(hit).AudioFile())
+	}
+	for id, path := range i.pending {
+		if path == af.Path {
+			ret = append(ret, AudioFile{ID: id, Path: path})
+		}
+	}
+	return ret, nil
+}
+
+// idTaken reports whether id is used by file at other path than given
+func (i *MediaIndex) idTaken(id string, path string) bool {
+	if pendingPath, ok := i.pending[id]; ok {
+		return pendingPath != path
+	}
+	indexed := i.GetPath(id)
+	return indexed != "" && indexed != path
+}
+
+// Duplicates returns groups of indexed files with same content, ordered by
+// path
+func (i *MediaIndex) Duplicates() ([]AudioFiles, error) {
+	items, err := i.Items()
+	if err != nil {
+		return nil, err
+	}
+	byContent := map[string]AudioFiles{}
+	for _, item := range items {
+		if item.Fingerprint != "" {
+			byContent[item.Fingerprint] = append(byContent[item.Fingerprint], item)
+		}
+	}
+	var ret []AudioFiles
+	for _, files := range byContent {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(a, b int) bool { return files[a].Path < files[b].Path })
+		ret = append(ret, files)
+	}
+	sort.Slice(ret, func(a, b int) bool { return ret[a][0].Path < ret[b][0].Path })
+	return ret, nil
+}
@@ -3,39 +3,141 @@ package media_index
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
-	"github.com/blevesearch/bleve/v2/analysis/lang/en"
 	"github.com/blevesearch/bleve/v2/mapping"
-	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/blevesearch/bleve_index_api"
 )
 
 var BatchSize = 1000
 var DefaultIndexName = "audio.bl"
 
-func createIndexMapping() (mapping.IndexMapping, error) {
+// DefaultFieldAnalyzers are analyzers used for audio file fields unless
+// configured otherwise, fields not listed here are not analyzed
+var DefaultFieldAnalyzers = map[string]string{
+	"Name":   SerbianAnalyzerName,
+	"Title":  SerbianAnalyzerName,
+	"Artist": SerbianAnalyzerName,
+	"Genre":  SerbianAnalyzerName,
+	"Album":  SerbianAnalyzerName,
+	"Folder": SerbianAnalyzerName,
+	"Tags":   SerbianAnalyzerName,
+}
+
+// indexedFields are audio file fields analyzers can be configured for
+var indexedFields = []string{"Path", "Folder", "Root", "Name", "Title", "Artist", "Album", "Genre", "Duration", "Tags"}
+
+// numericFields are audio file fields indexed as numbers
+var numericFields = []string{"Year", "Track", "DurationMs", "Bitrate", "SampleRate", "Channels", "Size"}
+
+// facetFields are not analyzed copies of fields, used for facets
+var facetFields = map[string]string{
+	"Genre":  "GenreFacet",
+	"Folder": "FolderFacet",
+}
+
+// suggestFields are copies of fields indexed with word prefixes, used for
+// suggestions
+var suggestFields = map[string]string{
+	"Name":   "NameSuggest",
+	"Artist": "ArtistSuggest",
+}
+
+// sortFields are not analyzed copies of fields, used for sorting
+var sortFields = map[string]string{
+	"Name":  "NameSort",
+	"Title": "TitleSort",
+}
+
+// FieldAnalyzers returns default field analyzers overridden by given ones
+func FieldAnalyzers(analyzers map[string]string) (map[string]string, error) {
+	ret := map[string]string{}
+	for field, analyzer := range DefaultFieldAnalyzers {
+		ret[field] = analyzer
+	}
+	for field, analyzer := range analyzers {
+		known := false
+		for _, f := range indexedFields {
+			if strings.EqualFold(f, field) {
+				ret[f] = analyzer
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %s, expected one of: %s", field, strings.Join(indexedFields, ", "))
+		}
+	}
+	return ret, nil
+}
 
-	engTextFieldMapping := bleve.NewTextFieldMapping()
-	engTextFieldMapping.Analyzer = en.AnalyzerName
+func createIndexMapping(analyzers map[string]string) (mapping.IndexMapping, error) {
 
-	keywordFieldMapping := bleve.NewTextFieldMapping()
-	keywordFieldMapping.Analyzer = keyword.Name
+	fieldAnalyzers, err := FieldAnalyzers(analyzers)
+	if err != nil {
+		return nil, err
+	}
 
 	audioMapping := bleve.NewDocumentMapping()
-	audioMapping.AddFieldMappingsAt("path", keywordFieldMapping)
-	audioMapping.AddFieldMappingsAt("folder", keywordFieldMapping)
-	audioMapping.AddFieldMappingsAt("name", engTextFieldMapping)
-	audioMapping.AddFieldMappingsAt("artist", engTextFieldMapping)
-	audioMapping.AddFieldMappingsAt("album", engTextFieldMapping)
-	audioMapping.AddFieldMappingsAt("tags", keywordFieldMapping)
-	audioMapping.AddFieldMappingsAt("duration", keywordFieldMapping)
-	audioMapping.AddFieldMappingsAt("root", keywordFieldMapping)
+	for _, field := range indexedFields {
+		fieldMapping := bleve.NewTextFieldMapping()
+		fieldMapping.Analyzer = keyword.Name
+		if analyzer, ok := fieldAnalyzers[field]; ok {
+			fieldMapping.Analyzer = analyzer
+		}
+		audioMapping.AddFieldMappingsAt(field, fieldMapping)
+	}
+	for field, facet := range facetFields {
+		facetMapping := bleve.NewTextFieldMapping()
+		facetMapping.Name = facet
+		facetMapping.Analyzer = keyword.Name
+		facetMapping.Store = false
+		facetMapping.IncludeInAll = false
+		audioMapping.AddFieldMappingsAt(field, facetMapping)
+	}
+	for field, suggest := range suggestFields {
+		suggestMapping := bleve.NewTextFieldMapping()
+		suggestMapping.Name = suggest
+		suggestMapping.Analyzer = SerbianSuggestAnalyzerName
+		suggestMapping.Store = false
+		suggestMapping.IncludeInAll = false
+		suggestMapping.IncludeTermVectors = false
+		audioMapping.AddFieldMappingsAt(field, suggestMapping)
+	}
+	for field, sort := range sortFields {
+		sortMapping := bleve.NewTextFieldMapping()
+		sortMapping.Name = sort
+		sortMapping.Analyzer = keyword.Name
+		sortMapping.Store = false
+		sortMapping.IncludeInAll = false
+		audioMapping.AddFieldMappingsAt(field, sortMapping)
+	}
+	for _, field := range numericFields {
+		numericMapping := bleve.NewNumericFieldMapping()
+		numericMapping.IncludeInAll = false
+		audioMapping.AddFieldMappingsAt(field, numericMapping)
+	}
+	// fingerprint finds moved files and duplicates
+	fingerprintMapping := bleve.NewTextFieldMapping()
+	fingerprintMapping.Analyzer = keyword.Name
+	fingerprintMapping.IncludeInAll = false
+	audioMapping.AddFieldMappingsAt("Fingerprint", fingerprintMapping)
+	// modification time is used to find changed files when index is updated
+	modTimeMapping := bleve.NewDateTimeFieldMapping()
+	modTimeMapping.IncludeInAll = false
+	audioMapping.AddFieldMappingsAt("ModTime", modTimeMapping)
 	indexMapping := bleve.NewIndexMapping()
-	indexMapping.AddDocumentMapping("audio", audioMapping)
-	indexMapping.DefaultAnalyzer = "en"
+	indexMapping.AddDocumentMapping(AudioFileType, audioMapping)
+	// used for queries not bound to a field
+	indexMapping.DefaultAnalyzer = SerbianAnalyzerName
+
+	if err := indexMapping.Validate(); err != nil {
+		return nil, err
+	}
 
 	return indexMapping, nil
 
@@ -49,10 +151,15 @@ type MediaIndex struct {
 	totalCount   int
 	BatchWritten chan int
 	Verbose      bool
+	// pending are paths of files added by id, in batch not written yet
+	pending map[string]string
+	// Analyzers overrides analyzers of fields when index is created, see
+	// DefaultFieldAnalyzers
+	Analyzers map[string]string
 }
 
 func (i *MediaIndex) Create() error {
-	indexMapping, err := createIndexMapping()
+	indexMapping, err := createIndexMapping(i.Analyzers)
 	if err != nil {
 		return err
 	}
@@ -94,6 +201,23 @@ func (i *MediaIndex) AddItem(item AudioFile) error {
 		i.sendBatchWritten(i.totalCount)
 		i.batch = i.index.NewBatch()
 		i.batchCount = 0
+		i.pending = nil
+	}
+	return nil
+}
+
+func (i *MediaIndex) DeleteItem(id string) error {
+	i.batch.Delete(id)
+	delete(i.pending, id)
+	i.batchCount++
+	if i.batchCount == BatchSize {
+		if err := i.index.Batch(i.batch); err != nil {
+			return err
+		}
+		i.sendBatchWritten(i.totalCount)
+		i.batch = i.index.NewBatch()
+		i.batchCount = 0
+		i.pending = nil
 	}
 	return nil
 }
@@ -105,6 +229,7 @@ func (i *MediaIndex) Flush() error {
 			return err
 		}
 		i.batchCount = 0
+		i.pending = nil
 		i.sendBatchWritten(i.totalCount)
 	}
 	return nil
@@ -122,72 +247,160 @@ func (i *MediaIndex) sendBatchWritten(count int) {
 	}
 }
 
-func (i *MediaIndex) Query(term string) (AudioFiles, error) {
-	qr := bleve.NewQueryStringQuery(term)
-	searchReq := bleve.NewSearchRequest(qr)
-	searchReq.Fields = []string{"ID", "Path", "Name", "Artist", "Duration", "Root"}
-	searchReq.From = 0
-	searchReq.Size = 10000
-	//data, err := json.Marshal(searchReq)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//fmt.Println(string(data))
-	if i.Verbose {
-		qs, err := query.DumpQuery(i.index.Mapping(), qr)
-		if err != nil {
-			return nil, err
+func number(field index.Field) int64 {
+	if nf, ok := field.(index.NumericField); ok {
+		if n, err := nf.Number(); err == nil {
+			return int64(n)
 		}
-		fmt.Println(qs)
 	}
-	res, err := i.index.Search(searchReq)
+	return 0
+}
+
+func audioFileFromDocument(doc index.Document) AudioFile {
+	af := AudioFile{
+		ID: doc.ID(),
+	}
+	doc.VisitFields(func(field index.Field) {
+		switch field.Name() {
+		case "Path":
+			af.Path = string(field.Value())
+		case "Fingerprint":
+			af.Fingerprint = string(field.Value())
+		case "Folder":
+			af.Folder = string(field.Value())
+		case "Name":
+			af.Name = string(field.Value())
+		case "Artist":
+			af.Artist = string(field.Value())
+		case "Album":
+			af.Album = string(field.Value())
+		case "Tags":
+			af.Tags = append(af.Tags, string(field.Value()))
+		case "Duration":
+			af.Duration = string(field.Value())
+		case "Root":
+			af.Root = string(field.Value())
+		case "Title":
+			af.Title = string(field.Value())
+		case "Genre":
+			af.Genre = string(field.Value())
+		case "Year":
+			af.Year = int(number(field))
+		case "Track":
+			af.Track = int(number(field))
+		case "DurationMs":
+			af.DurationMs = number(field)
+		case "Bitrate":
+			af.Bitrate = int(number(field))
+		case "SampleRate":
+			af.SampleRate = int(number(field))
+		case "Channels":
+			af.Channels = int(number(field))
+		case "Size":
+			af.Size = number(field)
+		case "ModTime":
+			if df, ok := field.(index.DateTimeField); ok {
+				if modTime, err := df.DateTime(); err == nil {
+					af.ModTime = modTime
+				}
+			}
+		}
+	})
+	return af
+}
+
+// Items returns all audio files stored in index
+func (i *MediaIndex) Items() (AudioFiles, error) {
+	var ret AudioFiles
+	err := visitDocuments(i.index, func(af AudioFile) error {
+		ret = append(ret, af)
+		return nil
+	})
+	return ret, err
+}
+
+// Rebuild recreates index with current mapping and analyzers, reusing
+// documents stored in existing index, so media does not need to be walked
+// again. Index must not be opened.
+func (i *MediaIndex) Rebuild() (int, error) {
+	old, err := bleve.Open(i.indexName)
 	if err != nil {
-		//log.Println(err)
-		return nil, err
+		return 0, fmt.Errorf("cannot open media index %s: %w", i.indexName, err)
 	}
-	var ret []AudioFile
 
-	for _, hit := range res.Hits {
-		doc, err := i.index.Document(hit.ID)
+	rebuildName := i.indexName + ".rebuild"
+	if err := os.RemoveAll(rebuildName); err != nil {
+		old.Close()
+		return 0, err
+	}
+	rebuilt := NewIndex(rebuildName)
+	rebuilt.Analyzers = i.Analyzers
+	rebuilt.BatchWritten = i.BatchWritten
+	if err := rebuilt.Create(); err != nil {
+		old.Close()
+		return 0, err
+	}
+
+	err = visitDocuments(old, rebuilt.AddItem)
+	if err == nil {
+		err = rebuilt.Flush()
+	}
+	if cerr := rebuilt.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := old.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(rebuildName)
+		return 0, err
+	}
+
+	// swap indexes, keeping old one until new is in place
+	backupName := i.indexName + ".old"
+	if err := os.RemoveAll(backupName); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(i.indexName, backupName); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(rebuildName, i.indexName); err != nil {
+		os.Rename(backupName, i.indexName)
+		return 0, err
+	}
+	return rebuilt.totalCount, os.RemoveAll(backupName)
+}
+
+func visitDocuments(from bleve.Index, visit func(AudioFile) error) error {
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), BatchSize, 0, false)
+	req.SortBy([]string{"_id"})
+	for {
+		res, err := from.Search(req)
 		if err != nil {
-			//log.Println(err)
-			return nil, err
-		}
-		af := AudioFile{
-			ID: hit.ID,
+			return err
 		}
-		doc.VisitFields(func(field index.Field) {
-			switch field.Name() {
-			case "Path":
-				af.Path = string(field.Value())
-			case "Folder":
-				af.Folder = string(field.Value())
-			case "Name":
-				af.Name = string(field.Value())
-			case "Artist":
-				af.Artist = string(field.Value())
-			case "Album":
-				af.Album = string(field.Value())
-			case "Tags":
-				af.Tags = []string{}
-			case "Duration":
-				//dur, err := time.ParseDuration(string(field.Value()))
-				//if err == nil {
-				//	af.Duration = dur
-				//}
-				af.Duration = string(field.Value())
-			case "Root":
-				af.Root = string(field.Value())
+		for _, hit := range res.Hits {
+			doc, err := from.Document(hit.ID)
+			if err != nil {
+				return err
 			}
-		})
-		ret = append(ret, af)
+			if doc == nil {
+				continue
+			}
+			if err := visit(audioFileFromDocument(doc)); err != nil {
+				return err
+			}
+		}
+		if len(res.Hits) < BatchSize {
+			return nil
+		}
+		req.SearchAfter = []string{res.Hits[len(res.Hits)-1].ID}
 	}
-	return ret, nil
 }
 
 func (i *MediaIndex) GetPath(id string) string {
 	doc, err := i.index.Document(id)
-	if err != nil {
+	if err != nil || doc == nil {
 		return ""
 	}
 	path := ""
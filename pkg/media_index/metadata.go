@@ -0,0 +1,121 @@
+package media_index
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+)
+
+const (
+	// MetadataAuto uses exiftool when it is installed, and builtin reader
+	// otherwise
+	MetadataAuto     = "auto"
+	MetadataExiftool = "exiftool"
+	MetadataBuiltin  = "builtin"
+)
+
+var DefaultMetadata = MetadataAuto
+
+// MetadataExtractor reads audio metadata, extractor is not safe for
+// concurrent use
+type MetadataExtractor interface {
+	// Extract fills metadata of file at path into af
+	Extract(path string, af *AudioFile) error
+	Close() error
+}
+
+// NewMetadataExtractor creates extractor for backend, one of MetadataAuto,
+// MetadataExiftool or MetadataBuiltin
+func NewMetadataExtractor(backend string) (MetadataExtractor, error) {
+	switch backend {
+	case MetadataAuto, "":
+		if _, err := exec.LookPath("exiftool"); err != nil {
+			return builtinExtractor{}, nil
+		}
+		return newExiftoolExtractor()
+	case MetadataExiftool:
+		return newExiftoolExtractor()
+	case MetadataBuiltin:
+		return builtinExtractor{}, nil
+	}
+	return nil, fmt.Errorf("unknown metadata reader %s, expected one of: %s, %s, %s",
+		backend, MetadataAuto, MetadataExiftool, MetadataBuiltin)
+}
+
+type exiftoolExtractor struct {
+	exif *exiftool.Exiftool
+}
+
+func newExiftoolExtractor() (MetadataExtractor, error) {
+	exif, err := exiftool.NewExiftool()
+	if err != nil {
+		return nil, err
+	}
+	return exiftoolExtractor{exif: exif}, nil
+}
+
+func (e exiftoolExtractor) Extract(path string, af *AudioFile) error {
+	fileInfos := e.exif.ExtractMetadata(path)
+	for _, fileInfo := range fileInfos {
+		if fileInfo.Err != nil {
+			return fileInfo.Err
+		}
+		// tag names differ between formats, first one found is used
+		value := func(names ...string) string {
+			for _, name := range names {
+				if v, err := fileInfo.GetString(name); err == nil && v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		af.Title = value("Title")
+		af.Album = value("Album")
+		af.Artist = value("Artist")
+		af.Genre = value("Genre")
+		af.Year = leadingInt(value("Year", "Date", "ContentCreateDate", "RecordingTime", "DateTimeOriginal"))
+		af.Track = leadingInt(value("Track", "TrackNumber"))
+		af.Bitrate = leadingInt(value("AudioBitrate", "AvgBitrate"))
+		af.SampleRate = leadingInt(value("SampleRate", "AudioSampleRate"))
+		af.Channels = leadingInt(value("Channels", "NumChannels", "AudioChannels"))
+		if af.Channels == 0 {
+			switch value("ChannelMode") {
+			case "":
+			case "Single Channel":
+				af.Channels = 1
+			default:
+				af.Channels = 2
+			}
+		}
+		if length := value("Duration"); length != "" {
+			af.Duration = makeNiceDuration(length)
+			af.DurationMs = parseDuration(length).Milliseconds()
+		}
+	}
+	return nil
+}
+
+func (e exiftoolExtractor) Close() error {
+	return e.exif.Close()
+}
+
+// leadingInt parses number value starts with, as in "128 kbps" or "3/12"
+func leadingInt(value string) int {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(value[:end])
+	return n
+}
+
+// formatDuration formats duration the way exiftool does for longer files
+func formatDuration(d time.Duration) string {
+	secs := int64(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
@@ -0,0 +1,598 @@
+package media_index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"ozz-ms/pkg/audio_info"
+)
+
+// maxTagSize limits tags read into memory, larger ones are mostly embedded
+// pictures
+const maxTagSize = 16 << 20
+
+var errUnknownFormat = errors.New("unknown audio format")
+
+// builtinExtractor reads tags of mp3, flac, ogg, mp4 and wav files without
+// external tools, stream properties are measured by audio_info
+type builtinExtractor struct {
+}
+
+// audioTags are tags read by builtin extractor
+type audioTags struct {
+	title  string
+	artist string
+	album  string
+	genre  string
+	year   int
+	track  int
+}
+
+// merge fills tags missing from found ones
+func (t *audioTags) merge(found audioTags) {
+	mergeString := func(to *string, from string) {
+		if *to == "" {
+			*to = strings.TrimSpace(from)
+		}
+	}
+	mergeInt := func(to *int, from int) {
+		if *to == 0 {
+			*to = from
+		}
+	}
+	mergeString(&t.title, found.title)
+	mergeString(&t.artist, found.artist)
+	mergeString(&t.album, found.album)
+	mergeString(&t.genre, found.genre)
+	mergeInt(&t.year, found.year)
+	mergeInt(&t.track, found.track)
+}
+
+func (e builtinExtractor) Extract(path string, af *AudioFile) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tags := audioTags{}
+	if err := readAudioTags(f, info.Size(), &tags); err != nil {
+		return err
+	}
+	af.Title = tags.title
+	af.Artist = tags.artist
+	af.Album = tags.album
+	af.Genre = tags.genre
+	af.Year = tags.year
+	af.Track = tags.track
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	stream, err := audio_info.Probe(f)
+	if err != nil {
+		return err
+	}
+	af.Bitrate = stream.Bitrate / 1000
+	af.SampleRate = stream.SampleRate
+	af.Channels = stream.Channels
+	if stream.Duration > 0 {
+		af.Duration = formatDuration(stream.Duration)
+		af.DurationMs = stream.Duration.Milliseconds()
+	}
+	return nil
+}
+
+func (e builtinExtractor) Close() error {
+	return nil
+}
+
+func readAudioTags(f io.ReadSeeker, size int64, tags *audioTags) error {
+	start := int64(0)
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(f, head[:10]); err != nil {
+		return err
+	}
+	if string(head[:3]) == "ID3" {
+		n, err := readID3v2(f, head[:10], tags)
+		if err != nil {
+			return err
+		}
+		start = n
+	}
+
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(f, head); err != nil {
+		return err
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	// mpeg audio has no tags other than id3
+	var err error
+	switch {
+	case string(head[:4]) == "fLaC":
+		err = readFLAC(f, tags)
+	case string(head[:4]) == "OggS":
+		err = readOgg(f, tags)
+	case string(head[4:8]) == "ftyp":
+		err = readMP4(f, size, tags)
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		err = readWAV(f, tags)
+	}
+	if err != nil {
+		return err
+	}
+
+	return readID3v1(f, size, tags)
+}
+
+// readID3v2 reads tag starting with header, returning offset of audio data
+func readID3v2(f io.Reader, header []byte, tags *audioTags) (int64, error) {
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	end := int64(10 + size)
+	if version == 4 && flags&0x10 != 0 {
+		// footer
+		end += 10
+	}
+	if size > maxTagSize {
+		return end, nil
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(f, body); err != nil {
+		return 0, err
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		// extended header
+		ext := int(binary.BigEndian.Uint32(body))
+		if version == 4 {
+			ext = syncsafe(body[:4])
+		} else {
+			ext += 4
+		}
+		if ext > len(body) {
+			return end, nil
+		}
+		body = body[ext:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	found := audioTags{}
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var frameSize int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		default:
+			frameSize = syncsafe(body[4:8])
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		}
+		if frameSize < 0 || headerLen+frameSize > len(body) {
+			break
+		}
+		frame := body[headerLen : headerLen+frameSize]
+		body = body[headerLen+frameSize:]
+
+		if version == 3 && frameFlags&0x00C0 != 0 {
+			// compressed or encrypted
+			continue
+		}
+		if version == 4 {
+			if frameFlags&0x000C != 0 {
+				continue
+			}
+			if frameFlags&0x0002 != 0 {
+				frame = bytes.ReplaceAll(frame, []byte{0xFF, 0x00}, []byte{0xFF})
+			}
+			if frameFlags&0x0001 != 0 && len(frame) >= 4 {
+				// data length indicator
+				frame = frame[4:]
+			}
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			found.title = decodeID3Text(frame)
+		case "TPE1", "TP1":
+			found.artist = decodeID3Text(frame)
+		case "TALB", "TAL":
+			found.album = decodeID3Text(frame)
+		case "TCON", "TCO":
+			found.genre = id3Genre(decodeID3Text(frame))
+		case "TYER", "TYE", "TDRC":
+			found.year = leadingInt(decodeID3Text(frame))
+		case "TRCK", "TRK":
+			found.track = leadingInt(decodeID3Text(frame))
+		}
+	}
+	tags.merge(found)
+	return end, nil
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// decodeID3Text decodes first value of text frame
+func decodeID3Text(frame []byte) string {
+	if len(frame) < 1 {
+		return ""
+	}
+	data := frame[1:]
+	var text string
+	switch frame[0] {
+	case 0:
+		text = latin1(data)
+	case 1:
+		text = utf16String(data, true)
+	case 2:
+		text = utf16String(data, false)
+	default:
+		text = string(data)
+	}
+	if i := strings.IndexRune(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func utf16String(b []byte, bom bool) string {
+	littleEndian := false
+	if bom && len(b) >= 2 {
+		if b[0] == 0xFF && b[1] == 0xFE {
+			littleEndian = true
+			b = b[2:]
+		} else if b[0] == 0xFE && b[1] == 0xFF {
+			b = b[2:]
+		}
+	}
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if littleEndian {
+			units = append(units, binary.LittleEndian.Uint16(b[i:]))
+		} else {
+			units = append(units, binary.BigEndian.Uint16(b[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// readID3v1 fills tags missing from other tags
+func readID3v1(f io.ReadSeeker, size int64, tags *audioTags) error {
+	if size < 128 {
+		return nil
+	}
+	if _, err := f.Seek(size-128, io.SeekStart); err != nil {
+		return err
+	}
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(f, tag); err != nil {
+		return err
+	}
+	if string(tag[:3]) != "TAG" {
+		return nil
+	}
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+	found := audioTags{
+		title:  field(tag[3:33]),
+		artist: field(tag[33:63]),
+		album:  field(tag[63:93]),
+		year:   leadingInt(field(tag[93:97])),
+	}
+	if tag[125] == 0 && tag[126] != 0 {
+		// id3v1.1 track number
+		found.track = int(tag[126])
+	}
+	if int(tag[127]) < len(id3Genres) {
+		found.genre = id3Genres[tag[127]]
+	}
+	tags.merge(found)
+	return nil
+}
+
+// readFLAC reads vorbis comment block, other metadata blocks are skipped
+func readFLAC(f io.ReadSeeker, tags *audioTags) error {
+	if _, err := f.Seek(4, io.SeekCurrent); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if blockType == 4 {
+			block := make([]byte, length)
+			if _, err := io.ReadFull(f, block); err != nil {
+				return err
+			}
+			readVorbisComments(block, tags)
+			return nil
+		}
+		if last {
+			return nil
+		}
+		if _, err := f.Seek(length, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+}
+
+func readVorbisComments(b []byte, tags *audioTags) {
+	if len(b) < 4 {
+		return
+	}
+	vendor := int(binary.LittleEndian.Uint32(b))
+	if 4+vendor+4 > len(b) {
+		return
+	}
+	b = b[4+vendor:]
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	found := audioTags{}
+	for n := 0; n < count && len(b) >= 4; n++ {
+		length := int(binary.LittleEndian.Uint32(b))
+		if 4+length > len(b) {
+			break
+		}
+		comment := string(b[4 : 4+length])
+		b = b[4+length:]
+		kv := strings.SplitN(comment, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "TITLE":
+			found.title = kv[1]
+		case "ARTIST":
+			found.artist = kv[1]
+		case "ALBUM":
+			found.album = kv[1]
+		case "GENRE":
+			found.genre = kv[1]
+		case "DATE", "YEAR":
+			found.year = leadingInt(kv[1])
+		case "TRACKNUMBER":
+			found.track = leadingInt(kv[1])
+		}
+	}
+	tags.merge(found)
+}
+
+// readOgg reads comment header of vorbis or opus stream, which is second
+// packet, spanning one or more pages
+func readOgg(f io.Reader, tags *audioTags) error {
+	var packets [][]byte
+	var packet []byte
+	header := make([]byte, 27)
+	for len(packets) < 2 {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return err
+		}
+		if string(header[:4]) != "OggS" {
+			return errUnknownFormat
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(f, segments); err != nil {
+			return err
+		}
+		for _, segment := range segments {
+			data := make([]byte, segment)
+			if _, err := io.ReadFull(f, data); err != nil {
+				return err
+			}
+			if len(packet)+len(data) > maxTagSize {
+				return nil
+			}
+			packet = append(packet, data...)
+			if segment < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	comments := packets[1]
+	switch {
+	case len(comments) > 7 && string(comments[:7]) == "\x03vorbis":
+		readVorbisComments(comments[7:], tags)
+	case len(comments) > 8 && string(comments[:8]) == "OpusTags":
+		readVorbisComments(comments[8:], tags)
+	}
+	return nil
+}
+
+// readMP4 reads iTunes style tags from movie atom
+func readMP4(f io.ReadSeeker, size int64, tags *audioTags) error {
+	movie, err := audio_info.ReadMP4Movie(f, size)
+	if err != nil {
+		return err
+	}
+
+	found := audioTags{}
+	var visit func(kind string, body []byte)
+	visit = func(kind string, body []byte) {
+		switch kind {
+		case "udta", "ilst":
+			audio_info.MP4Atoms(body, visit)
+		case "meta":
+			// full atom, version and flags precede children
+			if len(body) > 4 {
+				audio_info.MP4Atoms(body[4:], visit)
+			}
+		case "\xa9nam", "\xa9ART", "\xa9alb", "\xa9gen", "\xa9day", "gnre", "trkn":
+			var value []byte
+			audio_info.MP4Atoms(body, func(kind string, data []byte) {
+				if kind == "data" && len(data) > 8 {
+					value = data[8:]
+				}
+			})
+			switch kind {
+			case "\xa9nam":
+				found.title = string(value)
+			case "\xa9ART":
+				found.artist = string(value)
+			case "\xa9alb":
+				found.album = string(value)
+			case "\xa9gen":
+				found.genre = string(value)
+			case "\xa9day":
+				found.year = leadingInt(string(value))
+			case "gnre":
+				// id3v1 genre, counted from one
+				if len(value) >= 2 {
+					if n := int(binary.BigEndian.Uint16(value)); n > 0 && n <= len(id3Genres) {
+						found.genre = id3Genres[n-1]
+					}
+				}
+			case "trkn":
+				if len(value) >= 4 {
+					found.track = int(binary.BigEndian.Uint16(value[2:]))
+				}
+			}
+		}
+	}
+	audio_info.MP4Atoms(movie, visit)
+	tags.merge(found)
+	return nil
+}
+
+// readWAV reads LIST INFO chunk, other chunks are skipped
+func readWAV(f io.ReadSeeker, tags *audioTags) error {
+	if _, err := f.Seek(12, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	found := audioTags{}
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			break
+		}
+		id := string(header[:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:]))
+		next := size + size%2
+		if id == "LIST" && size >= 4 && size <= maxTagSize {
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(f, chunk); err != nil {
+				return err
+			}
+			next -= size
+			if string(chunk[:4]) == "INFO" {
+				readInfoList(chunk[4:], &found)
+			}
+		}
+		if _, err := f.Seek(next, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+	tags.merge(found)
+	return nil
+}
+
+func readInfoList(info []byte, found *audioTags) {
+	for len(info) >= 8 {
+		infoID := string(info[:4])
+		infoSize := int(binary.LittleEndian.Uint32(info[4:]))
+		if 8+infoSize > len(info) {
+			return
+		}
+		value := strings.TrimRight(string(info[8:8+infoSize]), "\x00 ")
+		switch infoID {
+		case "INAM":
+			found.title = value
+		case "IART":
+			found.artist = value
+		case "IPRD":
+			found.album = value
+		case "IGNR":
+			found.genre = value
+		case "ICRD":
+			found.year = leadingInt(value)
+		case "ITRK":
+			found.track = leadingInt(value)
+		}
+		if 8+infoSize+infoSize%2 > len(info) {
+			return
+		}
+		info = info[8+infoSize+infoSize%2:]
+	}
+}
+
+// id3Genre resolves genre given as id3v1 genre number, as "(17)" or "17"
+func id3Genre(genre string) string {
+	if strings.HasPrefix(genre, "(") {
+		if end := strings.Index(genre, ")"); end > 0 {
+			if rest := strings.TrimSpace(genre[end+1:]); rest != "" {
+				// refinement follows number
+				return rest
+			}
+			genre = genre[1:end]
+		}
+	}
+	if n, err := strconv.Atoi(genre); err == nil {
+		if n >= 0 && n < len(id3Genres) {
+			return id3Genres[n]
+		}
+		return ""
+	}
+	return genre
+}
+
+// id3Genres are genres defined by id3v1
+var id3Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
@@ -0,0 +1,65 @@
+package media_index
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinExtractor(t *testing.T) {
+	tests := []struct {
+		file       string
+		title      string
+		artist     string
+		album      string
+		genre      string
+		year       int
+		track      int
+		sampleRate int
+		channels   int
+		durationMs int64
+	}{
+		{"id3v2.mp3", "Špica za jutarnji program", "Radio Ozon", "Špice 2021", "Other", 2021, 3, 48000, 1, 1200},
+		{"id3v1.mp3", "Reklama pekara", "Pekara Klas", "Reklame", "Other", 2019, 7, 48000, 1, 1200},
+		{"vorbis.flac", "Džingl stanice", "Radio Ozon", "Džinglovi", "Jingle", 2020, 5, 44100, 2, 2000},
+		{"vorbis.ogg", "Upadica vesti", "Radio Ozon", "Upadice", "Speech", 2018, 2, 8000, 1, 1500},
+		{"itunes.m4a", "Maska emisije", "Radio Ozon", "Maske", "Pop", 2022, 9, 44100, 2, 2000},
+		{"list_info.wav", "Najava programa", "Radio Ozon", "Najave", "Speech", 2017, 4, 8000, 1, 1500},
+	}
+
+	extractor := builtinExtractor{}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			af := AudioFile{}
+			if err := extractor.Extract(filepath.Join("testdata", tt.file), &af); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if af.Title != tt.title {
+				t.Errorf("Title = %q, want %q", af.Title, tt.title)
+			}
+			if af.Artist != tt.artist {
+				t.Errorf("Artist = %q, want %q", af.Artist, tt.artist)
+			}
+			if af.Album != tt.album {
+				t.Errorf("Album = %q, want %q", af.Album, tt.album)
+			}
+			if af.Genre != tt.genre {
+				t.Errorf("Genre = %q, want %q", af.Genre, tt.genre)
+			}
+			if af.Year != tt.year {
+				t.Errorf("Year = %d, want %d", af.Year, tt.year)
+			}
+			if af.Track != tt.track {
+				t.Errorf("Track = %d, want %d", af.Track, tt.track)
+			}
+			if af.SampleRate != tt.sampleRate {
+				t.Errorf("SampleRate = %d, want %d", af.SampleRate, tt.sampleRate)
+			}
+			if af.Channels != tt.channels {
+				t.Errorf("Channels = %d, want %d", af.Channels, tt.channels)
+			}
+			if af.DurationMs != tt.durationMs {
+				t.Errorf("DurationMs = %d, want %d", af.DurationMs, tt.durationMs)
+			}
+		})
+	}
+}
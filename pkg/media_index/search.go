@@ -0,0 +1,252 @@
+package media_index
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var (
+	DefaultSearchSize = 50
+	MaxSearchSize     = 1000
+)
+
+var ErrInvalidSearch = errors.New("invalid search request")
+
+// SearchOptions select page, order and fields of search results
+type SearchOptions struct {
+	From int
+	// Size is number of hits returned, DefaultSearchSize if not set
+	Size int
+	// Sort lists fields to sort by, descending when prefixed with "-", as in
+	// "-Year" or "-_score", best scored hits first if not set
+	Sort []string
+	// Fields to return, all stored fields if not set
+	Fields []string
+}
+
+type SearchHit struct {
+	ID     string                 `json:"id"`
+	Score  float64                `json:"score"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+type SearchResults struct {
+	Total    uint64                  `json:"total"`
+	From     int                     `json:"from"`
+	Size     int                     `json:"size"`
+	TookMs   float64                 `json:"tookMs"`
+	MaxScore float64                 `json:"maxScore"`
+	Items    []SearchHit             `json:"items"`
+	Facets   map[string][]FacetCount `json:"facets"`
+}
+
+// storedFields are fields search hits can return
+func storedFields() []string {
+	fields := append([]string{}, indexedFields...)
+	fields = append(fields, numericFields...)
+	return append(fields, "Fingerprint", "ModTime")
+}
+
+// sortableFields maps fields hits can be sorted by to index fields
+func sortableFields() map[string]string {
+	fields := map[string]string{
+		"_score":  "_score",
+		"_id":     "_id",
+		"Path":    "Path",
+		"Root":    "Root",
+		"ModTime": "ModTime",
+	}
+	for _, field := range numericFields {
+		fields[field] = field
+	}
+	for field, facet := range facetFields {
+		fields[field] = facet
+	}
+	for field, sort := range sortFields {
+		fields[field] = sort
+	}
+	return fields
+}
+
+func knownField(field string, known []string) (string, bool) {
+	for _, k := range known {
+		if strings.EqualFold(k, field) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func (o SearchOptions) request(q query.Query) (*bleve.SearchRequest, error) {
+	size := o.Size
+	if size == 0 {
+		size = DefaultSearchSize
+	}
+	if size < 1 || size > MaxSearchSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidSearch, MaxSearchSize)
+	}
+	if o.From < 0 {
+		return nil, fmt.Errorf("%w: from must not be negative", ErrInvalidSearch)
+	}
+	req := bleve.NewSearchRequestOptions(q, size, o.From, false)
+
+	req.Fields = []string{"*"}
+	if len(o.Fields) > 0 {
+		req.Fields = []string{}
+		for _, f := range o.Fields {
+			field, ok := knownField(f, storedFields())
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown field %s", ErrInvalidSearch, f)
+			}
+			req.Fields = append(req.Fields, field)
+		}
+	}
+
+	if len(o.Sort) > 0 {
+		sortable := sortableFields()
+		names := make([]string, 0, len(sortable))
+		for name := range sortable {
+			names = append(names, name)
+		}
+		order := []string{}
+		for _, s := range o.Sort {
+			desc := strings.HasPrefix(s, "-")
+			name, ok := knownField(strings.TrimPrefix(s, "-"), names)
+			if !ok {
+				return nil, fmt.Errorf("%w: cannot sort by %s", ErrInvalidSearch, s)
+			}
+			field := sortable[name]
+			if desc {
+				field = "-" + field
+			}
+			order = append(order, field)
+		}
+		req.SortBy(order)
+	}
+	return req, nil
+}
+
+// Search returns page of audio files matching query string, with facet
+// counts of all matching files. Besides bleve query string syntax, query
+// can limit duration, as in "duration:<30s", "duration:>=3:00" or
+// "duration:1m..2m30s".
+func (i *MediaIndex) Search(term string, options SearchOptions) (*SearchResults, error) {
+	qr, err := parseQuery(term)
+	if err != nil {
+		return nil, err
+	}
+	searchReq, err := options.request(qr)
+	if err != nil {
+		return nil, err
+	}
+	addFacets(searchReq)
+	if i.Verbose {
+		qs, err := query.DumpQuery(i.index.Mapping(), qr)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(qs)
+	}
+	res, err := i.index.Search(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	ret := SearchResults{
+		Total:    res.Total,
+		From:     searchReq.From,
+		Size:     searchReq.Size,
+		TookMs:   float64(res.Took) / float64(time.Millisecond),
+		MaxScore: res.MaxScore,
+		Items:    make([]SearchHit, 0, len(res.Hits)),
+		Facets:   facetCounts(res.Facets),
+	}
+	for _, hit := range res.Hits {
+		ret.Items = append(ret.Items, searchHit(hit))
+	}
+	return &ret, nil
+}
+
+// searchHit normalizes stored field values, tags are always a list and
+// numbers are integers
+func searchHit(hit *search.DocumentMatch) SearchHit {
+	fields := make(map[string]interface{}, len(hit.Fields))
+	for name, value := range hit.Fields {
+		switch v := value.(type) {
+		case float64:
+			fields[name] = int64(v)
+		default:
+			fields[name] = v
+		}
+	}
+	if tags, ok := fields["Tags"]; ok {
+		switch v := tags.(type) {
+		case string:
+			fields["Tags"] = []string{v}
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, tag := range v {
+				list = append(list, fmt.Sprint(tag))
+			}
+			fields["Tags"] = list
+		}
+	}
+	return SearchHit{
+		ID:     hit.ID,
+		Score:  hit.Score,
+		Fields: fields,
+	}
+}
+
+// AudioFile returns audio file with fields hit has
+func (h SearchHit) AudioFile() AudioFile {
+	af := AudioFile{ID: h.ID}
+	text := func(name string) string {
+		v, _ := h.Fields[name].(string)
+		return v
+	}
+	number := func(name string) int64 {
+		v, _ := h.Fields[name].(int64)
+		return v
+	}
+	af.Path = text("Path")
+	af.Fingerprint = text("Fingerprint")
+	af.Folder = text("Folder")
+	af.Root = text("Root")
+	af.Name = text("Name")
+	af.Title = text("Title")
+	af.Artist = text("Artist")
+	af.Album = text("Album")
+	af.Genre = text("Genre")
+	af.Duration = text("Duration")
+	af.Year = int(number("Year"))
+	af.Track = int(number("Track"))
+	af.DurationMs = number("DurationMs")
+	af.Bitrate = int(number("Bitrate"))
+	af.SampleRate = int(number("SampleRate"))
+	af.Channels = int(number("Channels"))
+	af.Size = number("Size")
+	af.Tags, _ = h.Fields["Tags"].([]string)
+	if modTime, err := time.Parse(time.RFC3339, text("ModTime")); err == nil {
+		af.ModTime = modTime
+	}
+	return af
+}
+
+// Query returns audio files matching query string
+func (i *MediaIndex) Query(term string, options SearchOptions) (AudioFiles, uint64, error) {
+	res, err := i.Search(term, options)
+	if err != nil {
+		return nil, 0, err
+	}
+	ret := make(AudioFiles, 0, len(res.Items))
+	for _, hit := range res.Items {
+		ret = append(ret, hit.AudioFile())
+	}
+	return ret, res.Total, nil
+}
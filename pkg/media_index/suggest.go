@@ -0,0 +1,150 @@
+package media_index
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var (
+	DefaultSuggestSize = 10
+	MaxSuggestSize     = 50
+)
+
+// Suggestion is name or artist matching prefix, with files having it
+type Suggestion struct {
+	Text  string   `json:"text"`
+	Field string   `json:"field"`
+	IDs   []string `json:"ids"`
+}
+
+// Suggest returns distinct names and artists with words starting with words
+// of prefix, best matches first. Prefixes shorter than two letters match
+// nothing. Misspelled words of four or more letters are matched too, with
+// lower score.
+func (i *MediaIndex) Suggest(prefix string, size int) ([]Suggestion, error) {
+	if size == 0 {
+		size = DefaultSuggestSize
+	}
+	if size < 1 || size > MaxSuggestSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidSearch, MaxSuggestSize)
+	}
+	suggestions := []Suggestion{}
+	analyzer := i.index.Mapping().AnalyzerNamed(SerbianAnalyzerName)
+	if analyzer == nil {
+		return nil, fmt.Errorf("analyzer %s not found", SerbianAnalyzerName)
+	}
+	// words shorter than indexed prefixes are left out, longer ones are cut
+	var terms analysis.TokenStream
+	for _, token := range analyzer.Analyze([]byte(prefix)) {
+		term := []rune(string(token.Term))
+		if len(term) < minSuggestPrefix {
+			continue
+		}
+		if len(term) > maxSuggestPrefix {
+			term = term[:maxSuggestPrefix]
+		}
+		terms = append(terms, &analysis.Token{Term: []byte(string(term))})
+	}
+	if len(terms) == 0 {
+		return suggestions, nil
+	}
+
+	var disjuncts []query.Query
+	for _, field := range []string{"Name", "Artist"} {
+		exact := []query.Query{}
+		fuzzy := []query.Query{}
+		for _, token := range terms {
+			term := string(token.Term)
+			prefixQuery := bleve.NewTermQuery(term)
+			prefixQuery.SetField(suggestFields[field])
+			exact = append(exact, prefixQuery)
+			if utf8.RuneCountInString(term) < 4 {
+				fuzzy = append(fuzzy, prefixQuery)
+				continue
+			}
+			fuzzyQuery := bleve.NewFuzzyQuery(term)
+			fuzzyQuery.SetField(field)
+			fuzzyQuery.SetFuzziness(1)
+			fuzzy = append(fuzzy, bleve.NewDisjunctionQuery(prefixQuery, fuzzyQuery))
+		}
+		exactQuery := bleve.NewConjunctionQuery(exact...)
+		exactQuery.SetBoost(2)
+		disjuncts = append(disjuncts, exactQuery, bleve.NewConjunctionQuery(fuzzy...))
+	}
+
+	// several files usually share artist, so more hits are needed to find
+	// enough distinct suggestions
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(disjuncts...), size*5, 0, false)
+	req.Fields = []string{"Name", "Artist"}
+	res, err := i.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// prefix matches are suggested first, misspelled ones only when there
+	// are not enough of them
+	found := map[string]int{}
+	for _, fuzzy := range []bool{false, true} {
+		for _, hit := range res.Hits {
+			for _, field := range []string{"Name", "Artist"} {
+				text, _ := hit.Fields[field].(string)
+				if text == "" || !matchesPrefix(analyzer, text, terms, fuzzy) {
+					continue
+				}
+				key := field + ":" + strings.ToLower(text)
+				if n, ok := found[key]; ok {
+					if !containsString(suggestions[n].IDs, hit.ID) {
+						suggestions[n].IDs = append(suggestions[n].IDs, hit.ID)
+					}
+					continue
+				}
+				if len(suggestions) == size {
+					continue
+				}
+				found[key] = len(suggestions)
+				suggestions = append(suggestions, Suggestion{Text: text, Field: field, IDs: []string{hit.ID}})
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// matchesPrefix reports whether every term starts some word of text, when
+// fuzzy terms of four or more letters may also differ from word in a letter
+func matchesPrefix(analyzer *analysis.Analyzer, text string, terms analysis.TokenStream, fuzzy bool) bool {
+	words := analyzer.Analyze([]byte(text))
+	for _, term := range terms {
+		matched := false
+		for _, word := range words {
+			if strings.HasPrefix(string(word.Term), string(term.Term)) {
+				matched = true
+				break
+			}
+			if fuzzy && utf8.RuneCount(term.Term) >= 4 {
+				if _, exceeded := search.LevenshteinDistanceMax(string(term.Term), string(word.Term), 1); !exceeded {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
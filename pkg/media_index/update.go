@@ -0,0 +1,118 @@
+package media_index
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// UpdateReport counts changes made to index by Update
+type UpdateReport struct {
+	Added     int
+	Updated   int
+	Moved     int
+	Removed   int
+	Unchanged int
+}
+
+// Roots returns distinct roots of files stored in index
+func (i *MediaIndex) Roots() ([]string, error) {
+	items, err := i.Items()
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	found := map[string]bool{}
+	for _, item := range items {
+		if !found[item.Root] {
+			found[item.Root] = true
+			roots = append(roots, item.Root)
+		}
+	}
+	return roots, nil
+}
+
+// Update brings opened index in sync with files under given roots. Metadata
+// is read only for new files and files whose size or modification time
+// changed, and files no longer found are removed, unless they were moved
+// and keep their ID at new path. Files indexed under other roots are left as
+// they are. Optional indexed is called for every added, updated or moved
+// file.
+func (i *MediaIndex) Update(roots []string, options WalkerOptions, indexed func(item AudioFile)) (UpdateReport, error) {
+	report := UpdateReport{}
+
+	// missing root, e.g. unmounted share, would otherwise remove its files
+	for _, root := range roots {
+		if _, err := os.Stat(root); err != nil {
+			return report, fmt.Errorf("cannot update media index: %w", err)
+		}
+	}
+
+	items, err := i.Items()
+	if err != nil {
+		return report, err
+	}
+	existing := make(map[string]AudioFile, len(items))
+	for _, item := range items {
+		existing[item.Path] = item
+	}
+
+	seen := map[string]bool{}
+	options.Unchanged = func(path string, info fs.FileInfo) bool {
+		item, ok := existing[path]
+		// files indexed without fingerprint are read again, changed files are
+		// kept only when read as audio again and indexed under their old ID
+		if ok && item.Fingerprint != "" && item.Size == info.Size() && item.ModTime.Equal(info.ModTime()) {
+			seen[path] = true
+			report.Unchanged++
+			return true
+		}
+		return false
+	}
+	walker, err := NewAudioWalker(roots, options)
+	if err != nil {
+		return report, err
+	}
+
+	// walker is done with seen and report when file channel is closed
+	indexedIDs := map[string]bool{}
+	for audioFile := range walker.File {
+		moved, err := i.AddFile(&audioFile)
+		if err != nil {
+			// drain walker so it can finish
+			for range walker.File {
+			}
+			return report, err
+		}
+		indexedIDs[audioFile.ID] = true
+		if _, ok := existing[audioFile.Path]; ok {
+			report.Updated++
+		} else if moved {
+			report.Moved++
+		} else {
+			report.Added++
+		}
+		if indexed != nil {
+			indexed(audioFile)
+		}
+	}
+
+	walked := map[string]bool{}
+	for _, root := range roots {
+		walked[filepath.Clean(root)] = true
+	}
+	for _, item := range items {
+		if !walked[filepath.Clean(item.Root)] {
+			continue
+		}
+		if !seen[item.Path] && !indexedIDs[item.ID] {
+			if err := i.DeleteItem(item.ID); err != nil {
+				return report, err
+			}
+			report.Removed++
+		}
+	}
+
+	return report, i.Flush()
+}
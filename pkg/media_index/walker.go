@@ -7,114 +7,241 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/barasher/go-exiftool"
 	"github.com/h2non/filetype"
 )
 
+// DefaultWorkers is number of files read in parallel, each worker has its
+// own metadata extractor
+var DefaultWorkers = runtime.NumCPU()
+
+// WalkerOptions tune which files walker reads
+type WalkerOptions struct {
+	// Unchanged is called for every file found, and should report files
+	// already indexed with same size and modification time; their metadata is
+	// not read again and they are not sent to File channel
+	Unchanged func(path string, info fs.FileInfo) bool
+	// Workers reading metadata, DefaultWorkers if not set
+	Workers int
+	// Metadata is metadata reader used, DefaultMetadata if not set
+	Metadata string
+	// Filter selects files walked
+	Filter WalkFilter
+	// FindOnly finds audio files without reading their metadata
+	FindOnly bool
+}
+
 type AudioWalker struct {
-	File     chan AudioFile
+	File chan AudioFile
+	// Progress receives number of files queued for reading so far, new value
+	// is sent only when previous one was received
 	Progress <-chan int
 	Finished chan bool
-	exif     *exiftool.Exiftool
+	options  WalkerOptions
+	progress chan int
+	found    int
+	jobs     chan walkJob
+}
+
+// walkJob is file found by walker, to be read by one of workers
+type walkJob struct {
+	root string
+	path string
+	info fs.FileInfo
 }
 
-func NewAudioWalker(paths []string) (*AudioWalker, error) {
+func NewAudioWalker(paths []string, options WalkerOptions) (*AudioWalker, error) {
+	if err := options.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	if options.Workers < 1 {
+		options.Workers = DefaultWorkers
+	}
 	w := AudioWalker{
-		File:     make(chan AudioFile, 1),
-		Progress: make(chan int, 1),
+		File:     make(chan AudioFile, options.Workers),
 		Finished: make(chan bool, 1),
+		options:  options,
+		progress: make(chan int, 1),
+		jobs:     make(chan walkJob, options.Workers),
 	}
-	exif, err := exiftool.NewExiftool()
-	if err != nil {
-		return nil, err
+	w.Progress = w.progress
+
+	if options.Metadata == "" {
+		options.Metadata = DefaultMetadata
+	}
+	extractors := make([]MetadataExtractor, 0, options.Workers)
+	for n := 0; n < options.Workers; n++ {
+		if options.FindOnly {
+			extractors = append(extractors, nil)
+			continue
+		}
+		extractor, err := NewMetadataExtractor(options.Metadata)
+		if err != nil {
+			for _, e := range extractors {
+				e.Close()
+			}
+			return nil, err
+		}
+		extractors = append(extractors, extractor)
 	}
-	w.exif = exif
+
+	var workers sync.WaitGroup
+	for _, extractor := range extractors {
+		workers.Add(1)
+		go func(extractor MetadataExtractor) {
+			defer workers.Done()
+			if extractor != nil {
+				defer extractor.Close()
+			}
+			for job := range w.jobs {
+				if af, ok := readAudioFile(extractor, job.root, job.path, job.info); ok {
+					w.File <- af
+				}
+			}
+		}(extractor)
+	}
+
 	go func() {
 		defer close(w.File)
 		for _, fpath := range paths {
-			err := filepath.WalkDir(fpath, walk(w, fpath))
-			if err != nil {
-				log.Println("Error walking for:", fpath)
-			}
-
+			w.walk(fpath)
 		}
-		w.exif.Close()
+		close(w.jobs)
+		workers.Wait()
 		w.Finished <- true
 	}()
 
 	return &w, nil
 }
 
-func walk(w AudioWalker, fpath string) func(lpath string, d fs.DirEntry, err error) error {
-	return func(lpath string, d fs.DirEntry, err error) error {
-		if !d.IsDir() {
-			file, err := os.Open(lpath)
-			if err != nil {
-				log.Println("Skip indexing, error reading:", lpath)
-				return nil
-			}
-			header := make([]byte, 261)
-			n, err := file.Read(header)
-			if err != nil || n != 261 {
-				log.Println("Skip indexing, error reading header for:", lpath)
-				return nil
-			}
-			file.Close()
-			if filetype.IsAudio(header) {
+// walk queues files found under root
+func (w *AudioWalker) walk(root string) {
+	info, err := os.Stat(root)
+	if err != nil {
+		log.Println("Error walking for:", root)
+		return
+	}
+	if !info.IsDir() {
+		w.queue(root, root, info)
+		return
+	}
+	w.walkDir(root, root, map[string]bool{})
+}
 
-				hs, err := createHash(lpath)
-				if err != nil {
-					return nil
-				}
+// walkDir queues files in dir and its folders. Folders are walked once,
+// even when linked from several places, which also stops symlink loops.
+func (w *AudioWalker) walkDir(root string, dir string, visited map[string]bool) {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if visited[real] {
+			log.Println("Skip indexing, already walked:", dir)
+			return
+		}
+		visited[real] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Println("Skip indexing, error walking:", dir)
+		return
+	}
+	filter := w.options.Filter
+	for _, d := range entries {
+		lpath := filepath.Join(dir, d.Name())
+		if d.Type()&fs.ModeSymlink != 0 && !filter.FollowSymlinks {
+			continue
+		}
+		// links are followed by stat
+		info, err := os.Stat(lpath)
+		if err != nil {
+			log.Println("Skip indexing, error reading file info:", lpath)
+			continue
+		}
+		if !filter.Accepts(root, lpath, info.IsDir()) {
+			continue
+		}
+		if info.IsDir() {
+			w.walkDir(root, lpath, visited)
+			continue
+		}
+		w.queue(root, lpath, info)
+	}
+}
 
-				af := AudioFile{
-					ID:   hs,
-					Path: lpath,
-					Name: d.Name(),
-					Root: fpath,
-				}
+func (w *AudioWalker) queue(root string, lpath string, info fs.FileInfo) {
+	if !info.Mode().IsRegular() {
+		return
+	}
+	if w.options.Unchanged != nil && w.options.Unchanged(lpath, info) {
+		return
+	}
+	w.found++
+	select {
+	case w.progress <- w.found:
+	default:
+	}
+	w.jobs <- walkJob{root: root, path: lpath, info: info}
+}
 
-				fileInfos := w.exif.ExtractMetadata(lpath)
-				for _, fileInfo := range fileInfos {
-					if fileInfo.Err != nil {
-						continue
-					}
-					album, err := fileInfo.GetString("Album")
-					if err == nil {
-						af.Album = album
-					}
-					artist, err := fileInfo.GetString("Artist")
-					if err == nil {
-						af.Artist = artist
-					}
-					length, err := fileInfo.GetString("Duration")
-					if err == nil {
-						af.Duration = makeNiceDuration(length)
-					}
-					//for k, v := range fileInfo.Fields {
-					//	log.Printf("[%v]: %v\n", k, v)
-					//}
-				}
+// readAudioFile reads metadata of file at lpath found under root, reporting
+// false when it is not an audio file. Without extractor, only file info is
+// filled.
+func readAudioFile(extractor MetadataExtractor, root string, lpath string, info fs.FileInfo) (AudioFile, bool) {
+	file, err := os.Open(lpath)
+	if err != nil {
+		log.Println("Skip indexing, error reading:", lpath)
+		return AudioFile{}, false
+	}
+	header := make([]byte, 261)
+	n, err := file.Read(header)
+	file.Close()
+	if err != nil || n != 261 {
+		log.Println("Skip indexing, error reading header for:", lpath)
+		return AudioFile{}, false
+	}
+	if !filetype.IsAudio(header) {
+		return AudioFile{}, false
+	}
 
-				ldir, _ := filepath.Split(lpath)
-				rel, err := filepath.Rel(fpath, ldir)
-				if err != nil {
-					return nil
-				}
-				//_, folder := filepath.Split(rel)
-				af.Folder = rel
-				af.Tags = strings.Split(rel, string(filepath.Separator))
+	af := AudioFile{
+		Path:    lpath,
+		Name:    info.Name(),
+		Root:    root,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
 
-				w.File <- af
+	if extractor != nil {
+		fp, err := fingerprint(lpath, info.Size())
+		if err != nil {
+			log.Println("Skip indexing, error reading:", lpath)
+			return AudioFile{}, false
+		}
+		// ID is assigned when file is added to index, see MediaIndex.AddFile
+		af.ID = fp
+		af.Fingerprint = fp
 
-			}
-			return nil
+		if err := extractor.Extract(lpath, &af); err != nil {
+			log.Println("Error reading metadata for:", lpath, err)
+		}
+		if af.Bitrate == 0 && af.DurationMs > 0 {
+			// average bitrate, for formats not stating it
+			af.Bitrate = int(af.Size * 8 / af.DurationMs)
 		}
-		return nil
 	}
+
+	ldir, _ := filepath.Split(lpath)
+	rel, err := filepath.Rel(root, ldir)
+	if err != nil {
+		return AudioFile{}, false
+	}
+	//_, folder := filepath.Split(rel)
+	af.Folder = rel
+	af.Tags = strings.Split(rel, string(filepath.Separator))
+
+	return af, true
 }
 
 func createHash(filename string) (string, error) {
@@ -131,6 +258,8 @@ func createHash(filename string) (string, error) {
 func parseDuration(d string) time.Duration {
 	dd := strings.ReplaceAll(d, "(approx)", "")
 	dd = strings.TrimSpace(dd)
+	// short durations are given in seconds, as "12.34 s"
+	dd = strings.TrimSuffix(dd, " s")
 	dd = strings.Replace(dd, ":", "h", 1)
 	dd = strings.Replace(dd, ":", "m", 1)
 	dd = fmt.Sprintf("%ss", dd)
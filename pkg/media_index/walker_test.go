@@ -0,0 +1,67 @@
+package media_index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// sampleTree copies every testdata sample into several folders under a
+// temporary root, returning the root and number of files in it
+func sampleTree(b *testing.B, folders int) (string, int) {
+	samples, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	root := b.TempDir()
+	for n := 0; n < folders; n++ {
+		dir := filepath.Join(root, fmt.Sprintf("folder%02d", n))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for _, sample := range samples {
+			data, err := os.ReadFile(sample)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, filepath.Base(sample)), data, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root, folders * len(samples)
+}
+
+func BenchmarkAudioWalker(b *testing.B) {
+	root, total := sampleTree(b, 50)
+
+	workers := []int{1, 2, 4}
+	if cpus := runtime.NumCPU(); cpus > 4 {
+		workers = append(workers, cpus)
+	}
+	for _, n := range workers {
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				walker, err := NewAudioWalker([]string{root}, WalkerOptions{
+					Workers:  n,
+					Metadata: MetadataBuiltin,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				files := 0
+				for range walker.File {
+					files++
+				}
+				if files != total {
+					b.Fatalf("walker found %d files, want %d", files, total)
+				}
+			}
+			b.ReportMetric(float64(total*b.N)/time.Since(start).Seconds(), "files/s")
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package media_index
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/fsnotify/fsnotify"
+)
+
+var DefaultWatchDelay = 2 * time.Second
+
+// Watcher keeps opened index in sync with roots it watches. Changes are
+// collected until no new change is seen for Delay, and then applied as one
+// batch. Continuous changes are applied at least every ten delays.
+type Watcher struct {
+	Delay time.Duration
+	// Metadata is metadata reader used, DefaultMetadata if not set
+	Metadata string
+	// Filter selects files indexed, linked folders are not watched even
+	// when links are followed
+	Filter    WalkFilter
+	index     *MediaIndex
+	roots     []string
+	fsw       *fsnotify.Watcher
+	extractor MetadataExtractor
+	done      chan bool
+	wg        sync.WaitGroup
+}
+
+// NewWatcher creates watcher for roots, paths of files found must match
+// those used when index was created, so roots are given as stored in index
+func NewWatcher(index *MediaIndex, roots []string) *Watcher {
+	return &Watcher{
+		Delay:    DefaultWatchDelay,
+		Metadata: DefaultMetadata,
+		index:    index,
+		roots:    roots,
+		done:     make(chan bool),
+	}
+}
+
+func (w *Watcher) Start() error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.fsw = fsw
+	for _, root := range w.roots {
+		if err := w.watchDir(root, root); err != nil {
+			fsw.Close()
+			return err
+		}
+	}
+	extractor, err := NewMetadataExtractor(w.Metadata)
+	if err != nil {
+		fsw.Close()
+		return err
+	}
+	w.extractor = extractor
+	w.wg.Add(1)
+	go w.run()
+	return nil
+}
+
+// Close stops watching, changes still waiting for Delay are applied first
+func (w *Watcher) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	w.extractor.Close()
+	if err := w.index.Flush(); err != nil {
+		w.fsw.Close()
+		return err
+	}
+	return w.fsw.Close()
+}
+
+// watchDir adds watches for dir and all folders in it, except filtered out
+// ones
+func (w *Watcher) watchDir(root string, dir string) error {
+	return filepath.WalkDir(dir, func(lpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if !w.Filter.Accepts(root, lpath, true) {
+				return filepath.SkipDir
+			}
+			return w.fsw.Add(lpath)
+		}
+		return nil
+	})
+}
+
+// stat returns info of file at lpath, following link only when filter
+// allows it
+func (w *Watcher) stat(lpath string) (fs.FileInfo, bool, error) {
+	info, err := os.Lstat(lpath)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return info, true, nil
+	}
+	if !w.Filter.FollowSymlinks {
+		return info, false, nil
+	}
+	info, err = os.Stat(lpath)
+	return info, err == nil, nil
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(w.Delay)
+	timer.Stop()
+	var deadline time.Time
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			if len(pending) > 0 {
+				w.apply(pending)
+			}
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			now := time.Now()
+			if len(pending) == 0 {
+				deadline = now.Add(10 * w.Delay)
+			}
+			pending[event.Name] = true
+			wait := w.Delay
+			if left := deadline.Sub(now); left < wait {
+				wait = left
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Error watching media:", err)
+		case <-timer.C:
+			w.apply(pending)
+			pending = map[string]bool{}
+		}
+	}
+}
+
+// apply indexes or removes changed paths, renamed file shows up as removal of
+// old path and creation of new one
+func (w *Watcher) apply(paths map[string]bool) {
+	added, removed := 0, 0
+	// files are added first, so moved ones keep their IDs, and are written
+	// before removed paths are looked up
+	var missing []string
+	for lpath := range paths {
+		root, ok := w.rootOf(lpath)
+		if !ok {
+			continue
+		}
+		info, ok, err := w.stat(lpath)
+		if err != nil {
+			missing = append(missing, lpath)
+			continue
+		}
+		if !ok || !w.Filter.acceptsTree(root, lpath, info.IsDir()) {
+			continue
+		}
+		if info.IsDir() {
+			// folder moved in, or created with files already in it
+			if err := w.watchDir(root, lpath); err != nil {
+				log.Println("Error watching:", lpath, err)
+			}
+			_ = filepath.WalkDir(lpath, func(fpath string, d fs.DirEntry, err error) error {
+				if err != nil || fpath == lpath {
+					return nil
+				}
+				fi, ok, err := w.stat(fpath)
+				if err != nil || !ok || !w.Filter.Accepts(root, fpath, fi.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !fi.IsDir() && fi.Mode().IsRegular() && w.add(root, fpath, fi) {
+					added++
+				}
+				return nil
+			})
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if w.add(root, lpath, info) {
+			added++
+		}
+	}
+	if err := w.index.Flush(); err != nil {
+		log.Println("Error writing media index:", err)
+		return
+	}
+	for _, lpath := range missing {
+		n, err := w.remove(lpath)
+		if err != nil {
+			log.Println("Error removing from media index:", lpath, err)
+		}
+		removed += n
+	}
+	if err := w.index.Flush(); err != nil {
+		log.Println("Error writing media index:", err)
+		return
+	}
+	if added > 0 || removed > 0 {
+		log.Printf("Media index updated, indexed: %d, removed: %d", added, removed)
+	}
+}
+
+func (w *Watcher) add(root string, lpath string, info fs.FileInfo) bool {
+	af, ok := readAudioFile(w.extractor, root, lpath, info)
+	if !ok {
+		// file is no longer audio, e.g. overwritten
+		if _, err := w.remove(lpath); err != nil {
+			log.Println("Error removing from media index:", lpath, err)
+		}
+		return false
+	}
+	if _, err := w.index.AddFile(&af); err != nil {
+		log.Println("Error adding to media index:", lpath, err)
+		return false
+	}
+	return true
+}
+
+// remove deletes file, or all files in folder, at lpath
+func (w *Watcher) remove(lpath string) (int, error) {
+	ids, err := w.index.idsWithPath(lpath)
+	if err != nil {
+		return 0, err
+	}
+	inFolder, err := w.index.idsWithPathPrefix(lpath + string(filepath.Separator))
+	if err != nil {
+		return 0, err
+	}
+	ids = append(ids, inFolder...)
+	for _, id := range ids {
+		if err := w.index.DeleteItem(id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func (w *Watcher) rootOf(lpath string) (string, bool) {
+	for _, root := range w.roots {
+		croot := filepath.Clean(root)
+		if lpath == croot || strings.HasPrefix(lpath, croot+string(filepath.Separator)) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+func (i *MediaIndex) idsWithPath(path string) ([]string, error) {
+	qr := bleve.NewTermQuery(path)
+	qr.SetField("Path")
+	return i.ids(qr)
+}
+
+func (i *MediaIndex) idsWithPathPrefix(prefix string) ([]string, error) {
+	qr := bleve.NewPrefixQuery(prefix)
+	qr.SetField("Path")
+	return i.ids(qr)
+}
+
+func (i *MediaIndex) ids(qr query.Query) ([]string, error) {
+	req := bleve.NewSearchRequestOptions(qr, BatchSize, 0, false)
+	req.SortBy([]string{"_id"})
+	var ids []string
+	for {
+		res, err := i.index.Search(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		if len(res.Hits) < BatchSize {
+			return ids, nil
+		}
+		req.SearchAfter = []string{res.Hits[len(res.Hits)-1].ID}
+	}
+}
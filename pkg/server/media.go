@@ -2,7 +2,13 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+
+	"ozz-ms/pkg/media_index"
 
 	"github.com/labstack/echo/v4"
 )
@@ -31,9 +37,47 @@ func (s *OzzServer) getMediaStream(ctx echo.Context) error {
 
 func (s *OzzServer) searchMedia(ctx echo.Context) error {
 	q := ctx.QueryParam("q")
-	audioFiles, err := s.index.Query(q)
+	options := media_index.SearchOptions{}
+	for param, value := range map[string]*int{"from": &options.From, "size": &options.Size} {
+		if v := ctx.QueryParam(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", param, v))
+			}
+			*value = n
+		}
+	}
+	if sort := ctx.QueryParam("sort"); sort != "" {
+		options.Sort = strings.Split(sort, ",")
+	}
+	if fields := ctx.QueryParam("fields"); fields != "" {
+		options.Fields = strings.Split(fields, ",")
+	}
+	results, err := s.index.Search(q, options)
+	if err != nil {
+		if errors.Is(err, media_index.ErrInvalidSearch) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+	return ctx.JSON(200, results)
+}
+
+func (s *OzzServer) suggestMedia(ctx echo.Context) error {
+	size := 0
+	if v := ctx.QueryParam("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid size: %s", v))
+		}
+		size = n
+	}
+	suggestions, err := s.index.Suggest(ctx.QueryParam("prefix"), size)
 	if err != nil {
+		if errors.Is(err, media_index.ErrInvalidSearch) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return err
 	}
-	return ctx.JSON(200, audioFiles)
+	return ctx.JSON(200, suggestions)
 }
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"ozz-ms/pkg/media_index"
@@ -14,12 +15,20 @@ type OzzServerConfig struct {
 	IndexName string
 	Port      int
 	Verbose   bool
+	// Watch keeps index in sync with its media folders while server runs
+	Watch      bool
+	WatchDelay time.Duration
+	// Metadata is metadata reader used by watcher
+	Metadata string
+	// Filter selects files watcher indexes
+	Filter media_index.WalkFilter
 }
 
 type OzzServer struct {
-	Config OzzServerConfig
-	e      *echo.Echo
-	index  *media_index.MediaIndex
+	Config  OzzServerConfig
+	e       *echo.Echo
+	index   *media_index.MediaIndex
+	watcher *media_index.Watcher
 }
 
 func (s *OzzServer) Start() error {
@@ -28,6 +37,11 @@ func (s *OzzServer) Start() error {
 	if err != nil {
 		return err
 	}
+	if s.Config.Watch {
+		if err = s.startWatcher(); err != nil {
+			return err
+		}
+	}
 	err = s.e.Start(fmt.Sprintf(":%d", s.Config.Port))
 	if err != nil {
 		return err
@@ -45,6 +59,34 @@ func (s *OzzServer) Stop() error {
 		return err
 	}
 
+	if s.watcher != nil {
+		if err = s.watcher.Close(); err != nil {
+			return err
+		}
+		s.watcher = nil
+	}
+
+	return nil
+}
+
+func (s *OzzServer) startWatcher() error {
+	roots, err := s.index.Roots()
+	if err != nil {
+		return err
+	}
+	watcher := media_index.NewWatcher(s.index, roots)
+	if s.Config.Metadata != "" {
+		watcher.Metadata = s.Config.Metadata
+	}
+	if s.Config.WatchDelay > 0 {
+		watcher.Delay = s.Config.WatchDelay
+	}
+	watcher.Filter = s.Config.Filter
+	if err = watcher.Start(); err != nil {
+		return err
+	}
+	s.watcher = watcher
+	s.e.Logger.Infof("Watching media folders: %s", strings.Join(roots, ", "))
 	return nil
 }
 
@@ -57,6 +99,7 @@ func NewOzzServer(config OzzServerConfig) *OzzServer {
 	ozs.e.HidePort = true
 	ozs.index = media_index.NewIndex(config.IndexName)
 	ozs.e.GET("/media", ozs.searchMedia)
+	ozs.e.GET("/media/suggest", ozs.suggestMedia)
 	ozs.e.GET("/media/:id", ozs.getMedia)
 	ozs.e.GET("/media/stream/:id", ozs.getMediaStream)
 	return &ozs
@@ -1,6 +1,10 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
 	"time"
 )
 
@@ -13,3 +17,21 @@ func ParseDateString(dateStr string) (time.Time, error) {
 	return date, nil
 
 }
+
+// Checksum returns hex encoded SHA-256 of the reader content
+func Checksum(r io.Reader) (string, error) {
+	hs := sha256.New()
+	if _, err := io.Copy(hs, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hs.Sum(nil)), nil
+}
+
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Checksum(f)
+}